@@ -0,0 +1,375 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ---- Bots ----
+//
+// Bots fill the seats public lobbies couldn't. A bot is a Client without a
+// connection: its state handler, stats and powerups work like anyone's, but
+// writePump hands it the lobby's messages and run types back submissions the
+// way a browser would, at a speed and accuracy picked when it joins.
+
+var botNames = []string{
+	"Piston", "Clutch", "Torque", "Gasket", "Axle", "Sprocket", "Muffler",
+	"Throttle", "Camshaft", "Radiator", "Spark", "Chassis",
+}
+
+const botSuffix = " (bot)"
+
+// messages a bot can fall behind by before it misses some, see observe.
+// Changes to its words and place in them are never missed.
+const botInboxSize = 64
+
+// a bot waits this long after the start before its first word
+const botMinReaction = 300 * time.Millisecond
+const botMaxReaction = 900 * time.Millisecond
+
+// how much a bot's speed wanders from word to word, as the spread of a log
+// normal factor, and how often it stops to think
+const botJitter = 0.25
+const botHesitation = 0.05
+const botMaxHesitation = 600 * time.Millisecond
+
+// fog and tire boot slow bots down like they would a person
+const botSlowedDown = 0.7
+
+type bot struct {
+	client *Client
+	inbox  chan ServerMessage
+
+	// changes to the bot's words since run last looked, each folded into the
+	// one before so none are lost; wordsChanged says there are some
+	mu           sync.Mutex
+	pending      botWordState
+	wordsChanged chan struct{}
+
+	wpm float64
+	// chance of a wrong key for every right one, so accuracy comes out at
+	// 1 / (1 + errorRate)
+	errorRate float64
+}
+
+// backfill makes bots for the seats a public lobby has left when its wait
+// is over. Nobody races bots alone, so an empty lobby gets none.
+func (l *Lobby) backfill() []*Client {
+	if l.private || l.clientCount() == 0 {
+		return nil
+	}
+
+	seats := min(l.cfg.ClientsPerLobby-l.clientCount(), l.cfg.BotSeats)
+	names := rand.Perm(len(botNames))
+
+	var bots []*Client
+	for _, n := range names {
+		if len(bots) == seats {
+			break
+		}
+		name := botNames[n] + botSuffix
+		if l.hasPlayer(name) {
+			continue
+		}
+		bots = append(bots, newBot(l.hub, name))
+	}
+
+	if len(bots) > 0 {
+		l.log("backfilling %d seats with bots", len(bots))
+	}
+	return bots
+}
+
+func newBot(hub *Hub, name string) *Client {
+	cfg := hub.cfg
+	c := newClient(nil, hub)
+	c.name = name
+
+	accuracy := float64(cfg.BotMinAccuracy+rand.Intn(cfg.BotMaxAccuracy-cfg.BotMinAccuracy+1)) / 100
+	c.bot = &bot{
+		client:       c,
+		inbox:        make(chan ServerMessage, botInboxSize),
+		wordsChanged: make(chan struct{}, 1),
+		wpm:          float64(cfg.BotMinWpm + rand.Intn(cfg.BotMaxWpm-cfg.BotMinWpm+1)),
+		errorRate:    1/accuracy - 1,
+	}
+
+	go c.writePump()
+	return c
+}
+
+// observe is called by writePump, which can't wait on the bot: the state
+// handler may be waiting on writePump while the bot waits on the state
+// handler
+func (b *bot) observe(msg ServerMessage) {
+	if b.pendWords(msg) {
+		select {
+		case b.wordsChanged <- struct{}{}:
+		default:
+		}
+		return
+	}
+
+	select {
+	case b.inbox <- msg:
+	default:
+		b.client.log("bot fell behind, dropped message %d", msg.Opcode())
+	}
+}
+
+// botWordState is what word updates, rejections and skips the bot hasn't
+// applied yet add up to
+type botWordState struct {
+	update   *UpdateWordsMessage
+	rejected *SubmissionRejectedMessage
+	skipTo   uint32
+}
+
+// pendWords folds msg into the pending word state, if it's about words
+func (b *bot) pendWords(msg ServerMessage) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := &b.pending
+	switch msg := msg.(type) {
+	case UpdateWordsMessage:
+		if p.update != nil && msg.idx >= p.update.idx {
+			// the same as applying one after the other
+			keep := min(int(msg.idx-p.update.idx), len(p.update.words))
+			msg.words = append(slices.Clone(p.update.words[:keep]), msg.words...)
+			msg.idx = p.update.idx
+		}
+		p.update = &msg
+	case SubmissionRejectedMessage:
+		p.rejected = &msg
+	case WordsSkippedMessage:
+		p.skipTo = max(p.skipTo, msg.To)
+	default:
+		return false
+	}
+	return true
+}
+
+// takeWords hands over the pending word state and clears it
+func (b *bot) takeWords() botWordState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := b.pending
+	b.pending = botWordState{}
+	return p
+}
+
+// submit hands the state handler a message as if the bot had sent it
+func (b *bot) submit(msg ClientMessage) {
+	select {
+	case b.client.msgs <- msg:
+	case <-b.client.gone:
+	}
+}
+
+func (b *bot) run() {
+	c := b.client
+	c.log("bot typing at %.0f wpm, %.0f%% accuracy", b.wpm, 100/(1+b.errorRate))
+
+	var words []string
+	var opponents []ClientId
+	idx := 0
+	racing := false
+	slowed := false
+
+	var plans []powerupPlan
+	balance := 0
+	// when each powerup can be used again
+	var ready [PowerupCount]time.Time
+
+	typing := time.NewTimer(time.Hour)
+	typing.Stop()
+	defer typing.Stop()
+
+	next := func(delay time.Duration) {
+		if idx < len(words) {
+			typing.Reset(delay + b.wordDelay(words, idx, slowed))
+		}
+	}
+
+	for {
+		select {
+		case msg := <-b.inbox:
+			switch msg := msg.(type) {
+			case LobbyGreetingMessage:
+				words = slices.Clone(msg.Words)
+				for _, p := range msg.Players {
+					if p.ID != msg.PlayerID {
+						opponents = append(opponents, p.ID)
+					}
+				}
+				plans = b.planPowerups(msg.Powerups, len(words))
+
+			case NewRegisteredPlayerMessage:
+				if msg.Player.ID != c.id {
+					opponents = append(opponents, msg.Player.ID)
+				}
+
+			case TeamAssignmentMessage:
+				team := make(map[ClientId]TeamId)
+				for _, m := range msg.Members {
+					team[m.PlayerID] = m.Team
+				}
+				opponents = slices.DeleteFunc(opponents, func(id ClientId) bool {
+					return team[id] == team[c.id]
+				})
+
+			case RaceStartedMessage:
+				racing = true
+				reaction := botMinReaction + time.Duration(rand.Int63n(int64(botMaxReaction-botMinReaction)))
+				next(reaction)
+
+			case BalanceMessage:
+				balance = int(msg.Balance)
+
+			case StatusChangedV2Message:
+				if msg.PlayerID == c.id {
+					ids := msg.StatusEffectIDs()
+					slowed = slices.Contains(ids, byte(PowerupFog)) ||
+						slices.Contains(ids, byte(PowerupTireBoot))
+					for _, cd := range msg.Cooldowns {
+						ready[cd.PowerupID] = time.Now().Add(time.Duration(cd.Remaining) * time.Millisecond)
+					}
+				}
+			}
+
+		case <-b.wordsChanged:
+			p := b.takeWords()
+			waiting := idx >= len(words)
+
+			if p.rejected != nil {
+				idx = int(p.rejected.Index)
+			}
+			idx = max(idx, int(p.skipTo))
+			if p.update != nil {
+				words = append(words[:min(int(p.update.idx), len(words))], p.update.words...)
+			}
+
+			// streamed words in a time attack race, or a rejection sending
+			// the bot back after it ran out
+			if racing && waiting {
+				next(0)
+			}
+
+		case <-typing.C:
+			if idx >= len(words) {
+				continue
+			}
+
+			if mistakes := b.mistakes(len(words[idx])); mistakes > 0 {
+				b.submit(&KeystrokeReportMessage{
+					Incorrect:  uint16(mistakes),
+					Backspaces: uint16(mistakes),
+				})
+			}
+			b.submit(&WordSubmissionMessage{Index: uint32(idx), Word: words[idx]})
+			idx++
+
+			for i, plan := range plans {
+				cost := c.cfg.Powerups.cost(plan.powerup)
+				if idx < plan.at || balance < cost || time.Now().Before(ready[plan.powerup]) {
+					continue
+				}
+				if b.fire(plan.powerup, opponents) {
+					balance -= cost
+				}
+				// and again later if it can afford it
+				plans[i].at = idx + b.spacing(len(words))
+				break
+			}
+
+			next(0)
+
+		case <-c.kick:
+			return
+
+		case <-c.gone:
+			return
+		}
+	}
+}
+
+// wordDelay is how long typing words[i] takes, mistakes and all
+func (b *bot) wordDelay(words []string, i int, slowed bool) time.Duration {
+	wpm := b.wpm
+	if slowed {
+		wpm *= botSlowedDown
+	}
+	perChar := time.Minute / time.Duration(wpm*5)
+
+	// every mistake is a wrong key and a backspace, but they're decided when
+	// the word is sent so the delay only allows for the expected number
+	chars := float64(typedLength(words, i)) * (1 + 2*b.errorRate)
+	delay := time.Duration(chars * float64(perChar) * math.Exp(rand.NormFloat64()*botJitter))
+
+	if rand.Float64() < botHesitation {
+		delay += time.Duration(rand.Int63n(int64(botMaxHesitation)))
+	}
+	return delay
+}
+
+func (b *bot) mistakes(chars int) int {
+	n := 0
+	for range chars {
+		if rand.Float64() < b.errorRate {
+			n++
+		}
+	}
+	return n
+}
+
+// powerupPlan is when a bot means to buy a powerup next, once it's typed
+// that many words and has the coins
+type powerupPlan struct {
+	powerup PowerupId
+	at      int
+}
+
+// planPowerups picks which offered powerups to take and when to first use
+// them, somewhere after the start of the race
+func (b *bot) planPowerups(offered []int, words int) []powerupPlan {
+	c := b.client
+	picks := slices.Clone(offered)
+	rand.Shuffle(len(picks), func(i, j int) { picks[i], picks[j] = picks[j], picks[i] })
+	picks = picks[:min(c.cfg.AllowedPowerupCount, len(picks))]
+
+	ids := make([]byte, 0, len(picks))
+	plans := make([]powerupPlan, 0, len(picks))
+	for _, p := range picks {
+		ids = append(ids, byte(p))
+		plans = append(plans, powerupPlan{powerup: PowerupId(p), at: b.spacing(words)})
+	}
+
+	b.submit(&SelectPowerupsMessage{PowerupIDs: ids})
+	return plans
+}
+
+// spacing is how many words a bot types between uses of a powerup
+func (b *bot) spacing(words int) int {
+	return max(words/5, 1) + rand.Intn(max(words/3, 1))
+}
+
+// fire buys a powerup for a random opponent, or for the bot itself for the
+// mirror, and reports whether it had anyone to use it on
+func (b *bot) fire(p PowerupId, opponents []ClientId) bool {
+	target := b.client.id
+	if powerup, _ := lookupPowerup(byte(p)); !targetsSelf(powerup) {
+		if len(opponents) == 0 {
+			return false
+		}
+		target = opponents[rand.Intn(len(opponents))]
+	}
+
+	b.client.log("bot using %s on %d", p, target)
+	b.submit(&PowerupPurchaseMessage{PowerupID: byte(p), Affected: target})
+	return true
+}
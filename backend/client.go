@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"slices"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -14,9 +15,12 @@ type Client struct {
 	id   byte
 	name string
 
+	// the connection the client registered with, see writePump for the
+	// current one
 	conn *websocket.Conn
 
 	hub *Hub
+	cfg *Config
 
 	lobbyWrite chan ServerMessage
 
@@ -24,26 +28,80 @@ type Client struct {
 	lobbyRead     chan ClientLobbyMessage
 
 	unregister chan *Client
+	lobbyDone  chan struct{}
 	words      []string
+	// what each word was before any powerup got to it, see plainWords
+	plain [][]string
+
+	// closed by the lobby to hang up once everything queued has been sent
+	kick chan struct{}
+
+	// join code of the private lobby requested at registration
+	joinCode string
+	// when the client first joined the public queue, kept if a lobby bounces
+	// them back into it
+	queuedAt time.Time
+	// what was asked for when creating a private lobby
+	create lobbyOptions
+
+	// where the lobby's words come from
+	wordSource WordSource
+	// seconds in a time attack race, 0 for racing to the end of the words
+	timeAttack uint16
+
+	// set for bots, which have no connection and type server side
+	bot *bot
+
+	// spectators only
+	watchLobbyId int
+	unwatch      chan *Client
+
+	// resuming after a dropped connection
+	resumeToken string
+	msgs        chan ClientMessage
+	connLost    chan *websocket.Conn
+	resume      chan *websocket.Conn
+	setConn     chan *websocket.Conn
+	// closed once the client has left for good
+	gone chan struct{}
+
+	offeredPowerups []int
 
 	// room things
-	done   bool
-	closed bool
+	done bool
 
 	// calculating wpm
 	raceStart time.Time
+	// closed by the write pump once raceStart is set, before the client
+	// hears the race started
+	started chan struct{}
 }
 
-func (c *Client) readPump() {
-	stateHandlerDone := make(chan struct{})
-	msgs := make(chan ClientMessage)
-	go c.stateHandler(stateHandlerDone, msgs)
+func newClient(conn *websocket.Conn, hub *Hub) *Client {
+	return &Client{
+		conn: conn,
+		hub:  hub,
+
+		lobbyWrite:    make(chan ServerMessage),
+		lobbyMsgWrite: make(chan LobbyClientMessage),
+		kick:          make(chan struct{}),
+
+		msgs:     make(chan ClientMessage),
+		connLost: make(chan *websocket.Conn),
+		resume:   make(chan *websocket.Conn),
+		setConn:  make(chan *websocket.Conn),
+		gone:     make(chan struct{}),
+		started:  make(chan struct{}),
+	}
+}
 
+// readPump forwards messages from one connection to the state handler until
+// that connection fails, then reports it lost
+func (c *Client) readPump(conn *websocket.Conn) {
 	for {
-		_, message, err := c.conn.ReadMessage()
+		_, message, err := conn.ReadMessage()
 
 		if err != nil {
-			c.closed = true
 			if websocket.IsCloseError(err,
 				websocket.CloseNormalClosure,
 				websocket.CloseGoingAway,
@@ -56,10 +114,6 @@ func (c *Client) readPump() {
 			break
 		}
 
-		if c.closed {
-			continue
-		}
-
 		clientMessage, err := ParseClientMessage(message)
 
 		if err != nil {
@@ -70,23 +124,62 @@ func (c *Client) readPump() {
 		c.log("received client message: %d %T",
 			clientMessage.Opcode(), clientMessage)
 
-		msgs <- clientMessage
+		select {
+		case c.msgs <- clientMessage:
+		case <-c.gone:
+			return
+		}
 	}
 
-	c.log("unregistering")
-
-	c.closed = true
-	close(c.lobbyWrite)
-	close(stateHandlerDone)
-
-	c.unregister <- c
+	select {
+	case c.connLost <- conn:
+	case <-c.gone:
+	}
 }
 
+// writePump outlives any one connection: while detached, messages are dropped
+// since the lobby resends the full state when the client resumes
 func (c *Client) writePump() {
-	for msg := range c.lobbyWrite {
-		if _, ok := msg.(RaceStartedMessage); ok {
+	conn := c.conn
+
+writeLoop:
+	for {
+		var msg ServerMessage
+		select {
+		case m, ok := <-c.lobbyWrite:
+			if !ok {
+				break writeLoop
+			}
+			msg = m
+
+		case next := <-c.setConn:
+			if conn != nil && conn != next {
+				conn.Close()
+			}
+			conn = next
+			continue
+
+		case <-c.kick:
+			break writeLoop
+
+		case <-c.gone:
+			break writeLoop
+		}
+
+		if _, ok := msg.(RaceStartedMessage); ok && c.raceStart.IsZero() {
 			c.raceStart = time.Now()
+			close(c.started)
+		}
+
+		if c.bot != nil {
+			c.bot.observe(msg)
+			continue
+		}
+
+		if conn == nil {
+			continue
 		}
+
 		binaryMsg, err := msg.MarshalBinary()
 		if err != nil {
 			c.log("error marshaing binary: %+v", err)
@@ -95,260 +188,468 @@ func (c *Client) writePump() {
 
 		c.log("sending message: %d", msg.Opcode())
 
-		err = c.conn.WriteMessage(websocket.BinaryMessage, binaryMsg)
+		err = conn.WriteMessage(websocket.BinaryMessage, binaryMsg)
 
 		if err != nil {
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
 				c.log("Tried to write, websocket closed")
-				break
+			} else {
+				c.log("error writing server message to json %v", err)
 			}
-			c.log("error writing server message to json %v", err)
-			return
+			// readPump notices the closed connection and reports it lost
+			conn.Close()
+			conn = nil
 		}
 	}
 
-	if !c.closed {
-		c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+	if conn != nil {
+		conn.WriteMessage(websocket.CloseMessage, []byte{})
+		conn.Close()
 	}
 	c.log("writePump closed")
 }
 
-func (c *Client) stateHandler(done chan struct{}, msgs chan ClientMessage) {
+// send queues a message for the client, dropping it if they have left
+func (c *Client) send(msg ServerMessage) {
+	select {
+	case c.lobbyWrite <- msg:
+	case <-c.gone:
+	case <-c.kick:
+	}
+}
+
+// attach points writePump at a new connection, or nil while detached
+func (c *Client) attach(conn *websocket.Conn) {
+	select {
+	case c.setConn <- conn:
+	case <-c.kick:
+	}
+}
+
+// deliver hands the state handler a message from the lobby, dropping it if
+// the client has left
+func (c *Client) deliver(msg LobbyClientMessage) {
+	select {
+	case c.lobbyMsgWrite <- msg:
+	case <-c.gone:
+	}
+}
+
+func (c *Client) toLobby(msg ClientLobbyMessage) {
+	select {
+	case c.lobbyRead <- msg:
+	case <-c.lobbyDone:
+	}
+}
+
+// disconnect hangs up after anything already handed to writePump is sent
+func (c *Client) disconnect() {
+	close(c.kick)
+}
+
+// reject tells a client that never made it into a lobby why, then hangs up
+func (c *Client) reject(reason JoinFailedReason) {
+	c.lobbyWrite <- JoinFailedMessage{Reason: reason}
+	close(c.lobbyWrite)
+}
+
+func (c *Client) stateHandler() {
 	c.log("started state Handler")
 
+	defer c.leave()
+
 	// typing state
 	idx := 0
-	charsTyped := 0
+	var stats typingStats
+	finished := false
+	// progress from nitro on top of what was typed, in characters
+	var bonus float64
+	// the last words autocorrect typed, a submission for one of those was
+	// sent before the client heard
+	skipFrom, skipTo := 0, 0
 
 	// status effect states
-	var (
-		powerups         [PowerupCount]bool
-		usedPowerups     [PowerupCount]bool
-		statusEffects    [PowerupCount]bool
-		powerupsSelected bool = false
-
-		// status effect counters
-		wordsLeft [PowerupCount]int
-	)
-
-	// status effect timers
-	// start really long, then stop them later to reset to actual duration
-	fogTimer := time.NewTimer(time.Hour)
-	tireBootTimer := time.NewTimer(time.Hour)
-	rearViewMirrorTimer := time.NewTimer(time.Hour)
+	var purse wallet
+	var effects statusEffects
+	var cooling cooldowns
+
+	var powerups [PowerupCount]bool
+
+	pc := c.cfg.Powerups
+	cooldown := time.Duration(pc.Cooldown) * time.Second
+
+	// set for whichever timed effect runs out next
+	effectTimer := time.NewTimer(time.Hour)
+	effectTimer.Stop()
+
+	// connection state
+	conn := c.conn
+	resumeGrace := time.Duration(c.cfg.ResumeGrace) * time.Second
+	graceTimer := time.NewTimer(time.Hour)
+	graceTimer.Stop()
+
+	racing := func() bool {
+		select {
+		case <-c.started:
+			return true
+		default:
+			return false
+		}
+	}
+
+	statusChanged := func() {
+		effectTimer.Stop()
+		if next, ok := effects.nextExpiry(); ok {
+			effectTimer.Reset(time.Until(next))
+		}
+		c.toLobby(ClientLobbyStatusChanged{
+			clientId:  c.id,
+			effects:   effects.status(),
+			cooldowns: cooling.status(time.Now()),
+		})
+	}
+
+	expireEffects := func(expired []*activeEffect) {
+		if len(expired) == 0 {
+			return
+		}
+		for _, e := range expired {
+			c.log("%s ran out", e.powerup.Name())
+			if h, ok := e.powerup.(ExpireHook); ok {
+				h.Expire(&effectTarget{c: c, effects: &effects, idx: idx, from: e.from})
+			}
+		}
+		statusChanged()
+	}
+
+	// moved reports progress once idx has moved on, and finishes the race at
+	// the end of the words
+	moved := func() {
+		elapsed := time.Since(c.raceStart)
+
+		// nitro's bonus only moves the bar, it doesn't count as words typed
+		progress := passageProgress(c.words, idx, bonus)
+		if c.timeAttack > 0 {
+			progress = timeProgress(elapsed, c.timeAttack)
+			c.streamWords(idx)
+		}
+
+		c.toLobby(ClientLobbyProgressUpdate{
+			clientId: c.id,
+			progress: progress,
+			wpm:      int(stats.wpm(elapsed)),
+			rawWpm:   int(stats.rawWpm(elapsed)),
+			accuracy: stats.accuracy(),
+			words:    idx,
+		})
+
+		if progress >= 1 && c.timeAttack == 0 && !finished {
+			finished = true
+			c.toLobby(ClientLobbyFinished{
+				clientId: c.id,
+				wpm:      int(stats.wpm(elapsed)),
+				accuracy: stats.accuracy(),
+			})
+		}
+	}
+
+	// skipWords moves past n words without them being typed, so they count
+	// for progress but not wpm
+	skipWords := func(n int) {
+		n = min(n, len(c.words)-idx)
+		if n <= 0 || finished {
+			return
+		}
+		skipFrom, skipTo = idx, idx+n
+		idx += n
+		c.send(WordsSkippedMessage{From: uint32(skipFrom), To: uint32(skipTo)})
+
+		var expired []*activeEffect
+		for range n {
+			expired = append(expired, effects.wordTyped()...)
+		}
+		expireEffects(expired)
+		moved()
+	}
+
+	applyEffect := func(p Powerup, from ClientId) {
+		e, stronger := effects.add(p, from, pc, time.Now())
+		c.log("applying %s from %d, intensity %d", p.Name(), from, e.intensity)
+		if !stronger {
+			statusChanged()
+			return
+		}
+
+		t := &effectTarget{c: c, effects: &effects, idx: idx, from: from}
+		if tr, ok := p.(WordTransform); ok {
+			start := idx + pc.Offset
+			if start < len(c.words) {
+				t.rewrite(start, tr.Transform(t, c.words, start))
+			}
+		}
+		if h, ok := p.(ApplyHook); ok {
+			h.Apply(t)
+		}
+		if e.duration.Kind == DurationInstant {
+			effects.remove(e)
+		}
+		statusChanged()
+
+		switch {
+		case t.skip > 0:
+			skipWords(t.skip)
+		case idx >= len(c.words) && !finished:
+			// everything left was words a powerup added
+			moved()
+		}
+	}
 
 	for {
 		select {
 
-		case msg := <-msgs:
+		case msg := <-c.msgs:
 			switch msg := msg.(type) {
 			case *RegisterMessage:
 
 			case *SkipWaitMessage:
-				c.lobbyRead <- ClientLobbySkipWait{}
+				c.toLobby(ClientLobbySkipWait{})
 
 			case *SelectPowerupsMessage:
 				c.log("power ups selected: %+v", msg.PowerupIDs)
-				if powerupsSelected && len(msg.PowerupIDs) != 2 {
+				if len(msg.PowerupIDs) > c.cfg.AllowedPowerupCount {
+					c.log("can't select %d powerups, only %d", len(msg.PowerupIDs), c.cfg.AllowedPowerupCount)
 					continue
 				}
-				powerupsSelected = true
+				notOffered := func(id byte) bool {
+					return !slices.Contains(c.offeredPowerups, int(id))
+				}
+				if slices.ContainsFunc(msg.PowerupIDs, notOffered) {
+					c.log("can't select powerups that weren't offered: %v", c.offeredPowerups)
+					continue
+				}
+				// a new selection replaces the last one
+				powerups = [PowerupCount]bool{}
 				for _, id := range msg.PowerupIDs {
-					powerups[id] = true
+					if _, ok := lookupPowerup(id); ok {
+						powerups[id] = true
+					}
 				}
 
 			case *PowerupPurchaseMessage:
-				c.log("HEREERE")
 				pid := msg.PowerupID
-				if !powerups[pid] || usedPowerups[pid] {
-					c.log("DENIED")
+				if !racing() {
+					c.log("can't buy powerup %d before the race", pid)
+					c.send(PurchaseResultMessage{powerupID: pid, success: false, balance: uint32(purse.balance)})
+					continue
+				}
+				p, ok := lookupPowerup(pid)
+				if !ok || !powerups[pid] {
+					c.log("can't buy powerup %d, not selected", pid)
+					c.send(PurchaseResultMessage{powerupID: pid, success: false, balance: uint32(purse.balance)})
 					continue
 				}
-				usedPowerups[pid] = true
-				if pid == byte(PowerupRearViewMirror) {
-					c.log("received rear view mirror")
-					statusEffects[pid] = true
+				if !cooling.ready(p.Id(), time.Now()) {
+					c.log("can't use %s again yet", p.Name())
+					c.send(PurchaseResultMessage{powerupID: pid, success: false, balance: uint32(purse.balance)})
 					continue
 				}
+				if !purse.spend(pc.cost(p.Id())) {
+					c.log("can't afford %s with %d coins", p.Name(), purse.balance)
+					c.send(PurchaseResultMessage{powerupID: pid, success: false, balance: uint32(purse.balance)})
+					continue
+				}
+				cooling.start(p.Id(), cooldown, time.Now())
+				c.send(purse.message())
+				if targetsSelf(p) {
+					c.send(PurchaseResultMessage{powerupID: pid, success: true, balance: uint32(purse.balance)})
+					c.toLobby(ClientLobbyPowerupUsed{
+						clientId:  c.id,
+						powerupId: pid,
+					})
+					applyEffect(p, c.id)
+					continue
+				}
+				// the result waits on the lobby saying whether it landed
 				c.log("send status effect to %d", msg.Affected)
-				c.lobbyRead <- ClientLobbyApplyStatusEffect{
+				c.toLobby(ClientLobbyApplyStatusEffect{
 					affectedClientId: msg.Affected,
-					powerupId:        msg.PowerupID,
+					powerupId:        pid,
 					fromClientId:     c.id,
-				}
+				})
 
 			case *SubmissionMessage:
-				if msg.Answer == uint32(idx) {
-					// Track characters typed incrementally
-					if idx < len(c.words) {
-						charsTyped += len(c.words[idx])
-						// Add space after word (except for the last word)
-						if idx < len(c.words)-1 {
-							charsTyped++
-						}
-					}
-					idx++
+				c.log("ignoring unverified submission %d", msg.Answer)
 
-					secSpentRacing := float32(time.Since(c.raceStart)) / float32(time.Second)
-					// Avoid division by zero
-					if secSpentRacing <= 0 {
-						secSpentRacing = 0.001 // Use a small value to avoid division by zero
-					}
-					wordsCompleted := float32(charsTyped) / 5.0
-					wpm := 60 * wordsCompleted / secSpentRacing
+			case *WordSubmissionMessage:
+				if !racing() {
+					c.log("ignoring submission %d before the race", msg.Index)
+					continue
+				}
+				if finished || idx >= len(c.words) {
+					continue
+				}
 
-					c.lobbyRead <- ClientLobbyProgressUpdate{
-						clientId: c.id,
-						progress: float32(idx) / float32(len(c.words)),
-						wpm:      int(wpm),
-					}
+				if msg.Index >= uint32(skipFrom) && msg.Index < uint32(skipTo) {
+					c.log("late submission %d, autocorrect already typed it", msg.Index)
+					continue
+				}
 
-					for statusEffect := range wordsLeft {
-						if wordsLeft[statusEffect] == 0 {
-							continue
-						}
-						wordsLeft[statusEffect]--
-						if wordsLeft[statusEffect] == 0 {
-							statusEffects[statusEffect] = false
-							c.lobbyRead <- ClientLobbyStatusChanged{
-								clientId:   c.id,
-								powerupIds: getPowerups(statusEffects),
-							}
-						}
+				if msg.Index != uint32(idx) || !matchesUnit(c.words[idx], msg.Word) {
+					c.log("rejected submission %d %q, expected %d %q",
+						msg.Index, msg.Word, idx, c.words[idx])
+					stats.rejected++
+					c.send(SubmissionRejectedMessage{Index: uint32(idx)})
+					if purse.breakStreak() {
+						c.send(purse.message())
 					}
+					continue
 				}
 
-				if idx == len(c.words) {
-					c.lobbyRead <- ClientLobbyFinished{
-						clientId: c.id,
-					}
+				// Track characters typed incrementally
+				typed := typedLength(c.words, idx)
+				stats.charsTyped += typed
+				bonus += float64(typed) * (effects.progressMultiplier(pc) - 1)
+				idx++
+
+				purse.earn(pc)
+				c.send(purse.message())
+
+				expireEffects(effects.wordTyped())
+				moved()
+
+			case *KeystrokeReportMessage:
+				stats.incorrect += int(msg.Incorrect)
+				stats.backspaces += int(msg.Backspaces)
+				if msg.Incorrect > 0 && purse.breakStreak() {
+					c.send(purse.message())
 				}
 			}
 
 		case msg := <-c.lobbyMsgWrite:
 			switch msg := msg.(type) {
+			case LobbyClientPurchaseLanded:
+				p, ok := lookupPowerup(msg.powerupId)
+				if !ok {
+					continue
+				}
+				if !msg.landed {
+					c.log("%s didn't land, refunding", p.Name())
+					purse.balance += pc.cost(p.Id())
+					cooling.clear(p.Id())
+					c.send(PurchaseResultMessage{powerupID: msg.powerupId, success: false, balance: uint32(purse.balance)})
+					c.send(purse.message())
+					continue
+				}
+				c.send(PurchaseResultMessage{powerupID: msg.powerupId, success: true, balance: uint32(purse.balance)})
+				c.toLobby(ClientLobbyPowerupUsed{
+					clientId:  c.id,
+					powerupId: msg.powerupId,
+				})
+				statusChanged()
+
 			case LobbyClientApplyStatusEffect:
-				c.log("Recieved power up %d", msg.powerupId)
-				if statusEffects[PowerupRearViewMirror] {
-					c.lobbyRead <- ClientLobbyApplyStatusEffect{
+				p, ok := lookupPowerup(msg.powerupId)
+				if !ok {
+					c.toLobby(ClientLobbyEffectBlocked{clientId: c.id})
+					continue
+				}
+				c.log("Recieved power up %s", p.Name())
+
+				if mirror, ok := effects.reflector(p); ok {
+					c.log("%s sends %s back to %d", mirror.powerup.Name(), p.Name(), msg.fromClientId)
+					c.toLobby(ClientLobbyEffectBlocked{clientId: c.id})
+					c.toLobby(ClientLobbyApplyStatusEffect{
 						affectedClientId: msg.fromClientId,
 						powerupId:        msg.powerupId,
 						fromClientId:     c.id,
-					}
-					statusEffects[PowerupRearViewMirror] = false
-					c.lobbyRead <- ClientLobbyStatusChanged{
-						clientId:   c.id,
-						powerupIds: getPowerups(statusEffects),
-					}
-
-					rearViewMirrorTimer.Stop()
-					<-rearViewMirrorTimer.C // drain
-
+						reflected:        true,
+					})
+					effects.remove(mirror)
+					statusChanged()
 					continue
 				}
-				statusEffects[msg.powerupId] = true
-				c.lobbyRead <- ClientLobbyStatusChanged{
-					clientId:   c.id,
-					powerupIds: getPowerups(statusEffects),
+
+				if shield, ok := effects.absorber(p); ok {
+					c.log("%s stops %s from %d", shield.powerup.Name(), p.Name(), msg.fromClientId)
+					c.toLobby(ClientLobbyEffectBlocked{clientId: c.id})
+					effects.remove(shield)
+					statusChanged()
+					continue
 				}
 
-				switch PowerupId(msg.powerupId) {
-				case PowerupCount:
-
-				case PowerupFog:
-					fogTimer.Stop()
-					fogTimer.Reset(fogDuration * time.Second)
-
-				case PowerupIcyRoads:
-					lidx := idx + powerupOffset
-					if lidx < len(c.words) {
-						c.words = RepeatCharsRange(c.words, powerupOffset, wordsIced)
-						c.lobbyWrite <- UpdateWordsMessage{
-							idx:   uint32(lidx),
-							words: c.words[lidx:],
-						}
-						wordsLeft[PowerupIcyRoads] = wordsIced
-					}
+				c.toLobby(ClientLobbyEffectReceived{
+					clientId:     c.id,
+					fromClientId: msg.fromClientId,
+					powerupId:    msg.powerupId,
+				})
+				applyEffect(p, msg.fromClientId)
+			}
 
-				case PowerupRearViewMirror:
-					rearViewMirrorTimer.Stop()
-					rearViewMirrorTimer.Reset(rearViewMirrorDuration * time.Second)
-
-				case PowerupScrambler:
-					lidx := idx + powerupOffset
-					if lidx < len(c.words) {
-						c.words = ScrambleRange(c.words, powerupOffset, wordsScrambled)
-						c.lobbyWrite <- UpdateWordsMessage{
-							idx:   uint32(lidx),
-							words: c.words[lidx:],
-						}
-						wordsLeft[PowerupScrambler] = wordsScrambled
-					}
+		case <-effectTimer.C:
+			expireEffects(effects.expireDue(time.Now()))
 
-				case PowerupSpikeStrip:
-					lidx := idx + powerupOffset
-					if lidx < len(c.words) {
-						c.words = append(c.words, RandomWords(wordsEnglish, spikeStripWordsAdded)...)
-						c.lobbyWrite <- UpdateWordsMessage{
-							idx:   uint32(lidx),
-							words: c.words[lidx:],
-						}
-						wordsLeft[PowerupSpikeStrip] = spikeStripWordsAdded
-					}
+		case lost := <-c.connLost:
+			// a replaced connection failing doesn't matter
+			if lost != conn {
+				continue
+			}
+			conn = nil
+			c.attach(nil)
 
-				case PowerupStickShift:
-					lidx := idx + powerupOffset
-					if lidx < len(c.words) {
-						c.words = ObfuscateRange(c.words, powerupOffset, wordsStickShifted)
-						c.lobbyWrite <- UpdateWordsMessage{
-							idx:   uint32(lidx),
-							words: c.words[lidx:],
-						}
-						wordsLeft[PowerupStickShift] = wordsStickShifted
-					}
+			if resumeGrace <= 0 {
+				c.log("connection lost")
+				return
+			}
+			c.log("connection lost, holding state for %s", resumeGrace)
+			graceTimer.Reset(resumeGrace)
+
+		case next := <-c.resume:
+			c.log("resuming")
+			graceTimer.Stop()
 
-				case PowerupTireBoot:
-					tireBootTimer.Stop()
-					tireBootTimer.Reset(tireBootDuration * time.Second)
+			conn = next
+			c.attach(next)
+			go c.readPump(next)
+
+			offered := make([]byte, 0, len(powerups))
+			for pid, selected := range powerups {
+				if selected {
+					offered = append(offered, byte(pid))
 				}
 			}
 
-		case <-fogTimer.C:
-			statusEffects[PowerupFog] = false
-			c.lobbyRead <- ClientLobbyStatusChanged{
-				clientId:   c.id,
-				powerupIds: getPowerups(statusEffects),
-			}
-		case <-tireBootTimer.C:
-			statusEffects[PowerupTireBoot] = false
-			c.lobbyRead <- ClientLobbyStatusChanged{
-				clientId:   c.id,
-				powerupIds: getPowerups(statusEffects),
-			}
-		case <-rearViewMirrorTimer.C:
-			statusEffects[PowerupRearViewMirror] = false
-			c.lobbyRead <- ClientLobbyStatusChanged{
-				clientId:   c.id,
-				powerupIds: getPowerups(statusEffects),
-			}
+			c.toLobby(ClientLobbyResumed{
+				clientId: c.id,
+				idx:      idx,
+				words:    append([]string{}, c.words...),
+				powerups: offered,
+				purse:    purse,
+			})
 
-		case <-done:
+		case <-graceTimer.C:
+			c.log("resume grace period over")
+			return
+
+		case <-c.kick:
 			c.log("closing state handler")
 			return
 		}
 	}
 }
 
-func (c *Client) log(format string, v ...any) {
-	log.Printf("client %d: %s", c.id, fmt.Sprintf(format, v...))
-}
+// leave gives up the client's place in the lobby for good
+func (c *Client) leave() {
+	c.log("unregistering")
 
-func getPowerups(statusEffects [PowerupCount]bool) []byte {
-	pids := make([]byte, 0, PowerupCount)
-	for i, t := range statusEffects {
-		if t {
-			pids = append(pids, byte(i))
-		}
+	c.hub.sessions.remove(c.resumeToken)
+	close(c.gone)
+
+	select {
+	case c.unregister <- c:
+	case <-c.lobbyDone:
 	}
-	return pids
+}
+
+func (c *Client) log(format string, v ...any) {
+	log.Printf("client %d: %s", c.id, fmt.Sprintf(format, v...))
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"strings"
 )
 
 // ---- Opcode enum ----
@@ -15,6 +16,12 @@ const (
 	OpcodePowerupPurchase Opcode = 2
 	OpcodeSkipWait        Opcode = 3
 	OpcodeSelectPowerups  Opcode = 4
+	OpcodeCreateLobby     Opcode = 5
+	OpcodeWordSubmission  Opcode = 6
+	OpcodeKeystrokeReport Opcode = 7
+	OpcodeResume          Opcode = 8
+	OpcodeSpectate        Opcode = 9
+	OpcodeWatchReplay     Opcode = 10
 )
 
 // ---- ClientMessage interface ----
@@ -24,8 +31,10 @@ type ClientMessage interface {
 }
 
 // ---- Register (Opcode 0) ----
+// an optional join code may follow the name to join a private lobby
 type RegisterMessage struct {
 	Name string
+	Code string
 }
 
 func (m *RegisterMessage) Opcode() Opcode {
@@ -43,10 +52,23 @@ func (m *RegisterMessage) UnmarshalBinary(data []byte) error {
 	}
 
 	m.Name = string(data[1 : 1+nameLen])
+
+	rest := data[1+nameLen:]
+	if len(rest) == 0 {
+		return nil
+	}
+
+	codeLen := int(rest[0])
+	if len(rest) < 1+codeLen {
+		return fmt.Errorf("register: invalid code length")
+	}
+
+	m.Code = strings.ToUpper(string(rest[1 : 1+codeLen]))
 	return nil
 }
 
 // ---- Submission of a letter (Opcode 1) ----
+// deprecated: carries only the index, superseded by WordSubmissionMessage
 type SubmissionMessage struct {
 	Answer uint32
 }
@@ -118,6 +140,194 @@ func (m *SelectPowerupsMessage) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+// ---- Create Lobby (Opcode 5) ----
+// the word source and its language may follow the name, each length
+// prefixed, see wordSources.resolve. After them a uint16 makes the race a
+// time attack of that many seconds, and a byte splits it into that many teams.
+type CreateLobbyMessage struct {
+	Name       string
+	Source     string
+	Language   string
+	TimeAttack uint16
+	Teams      byte
+}
+
+func (*CreateLobbyMessage) Opcode() Opcode {
+	return OpcodeCreateLobby
+}
+
+func (m *CreateLobbyMessage) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("create lobby: data too short")
+	}
+
+	nameLen := int(data[0])
+	if len(data) < 1+nameLen {
+		return fmt.Errorf("create lobby: invalid name length")
+	}
+
+	m.Name = string(data[1 : 1+nameLen])
+
+	rest := data[1+nameLen:]
+	for _, field := range []*string{&m.Source, &m.Language} {
+		if len(rest) == 0 {
+			return nil
+		}
+
+		n := int(rest[0])
+		if len(rest) < 1+n {
+			return fmt.Errorf("create lobby: invalid word source length")
+		}
+
+		*field = string(rest[1 : 1+n])
+		rest = rest[1+n:]
+	}
+
+	if len(rest) == 0 {
+		return nil
+	}
+	if len(rest) != 2 && len(rest) != 3 {
+		return fmt.Errorf("create lobby: expected time attack and teams, got %d bytes", len(rest))
+	}
+
+	m.TimeAttack = binary.BigEndian.Uint16(rest)
+	if len(rest) == 3 {
+		m.Teams = rest[2]
+	}
+	return nil
+}
+
+// ---- Word Submission (Opcode 6) ----
+// code races' line breaks may be sent as a bare "\n", see matchesUnit
+type WordSubmissionMessage struct {
+	Index uint32
+	Word  string
+}
+
+func (*WordSubmissionMessage) Opcode() Opcode {
+	return OpcodeWordSubmission
+}
+
+func (m *WordSubmissionMessage) UnmarshalBinary(data []byte) error {
+	if len(data) < 5 {
+		return fmt.Errorf("word submission: data too short")
+	}
+
+	m.Index = binary.BigEndian.Uint32(data[:4])
+
+	wordLen := int(data[4])
+	if len(data) != 5+wordLen {
+		return fmt.Errorf("word submission: invalid word length")
+	}
+
+	m.Word = string(data[5 : 5+wordLen])
+	return nil
+}
+
+// ---- Keystroke Report (Opcode 7) ----
+// counts of mistakes made since the previous report
+type KeystrokeReportMessage struct {
+	Incorrect  uint16
+	Backspaces uint16
+}
+
+func (*KeystrokeReportMessage) Opcode() Opcode {
+	return OpcodeKeystrokeReport
+}
+
+func (m *KeystrokeReportMessage) UnmarshalBinary(data []byte) error {
+	if len(data) != 4 {
+		return fmt.Errorf("keystroke report: expected 4 bytes, got %d", len(data))
+	}
+
+	m.Incorrect = binary.BigEndian.Uint16(data[0:2])
+	m.Backspaces = binary.BigEndian.Uint16(data[2:4])
+	return nil
+}
+
+// ---- Resume (Opcode 8) ----
+// sent instead of Register to pick a dropped race back up
+type ResumeMessage struct {
+	Token string
+}
+
+func (*ResumeMessage) Opcode() Opcode {
+	return OpcodeResume
+}
+
+func (m *ResumeMessage) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("resume: data too short")
+	}
+
+	tokenLen := int(data[0])
+	if len(data) != 1+tokenLen {
+		return fmt.Errorf("resume: invalid token length")
+	}
+
+	m.Token = string(data[1:])
+	return nil
+}
+
+// ---- Spectate (Opcode 9) ----
+// watch a public lobby by id, or any lobby by join code if one follows the id
+type SpectateMessage struct {
+	LobbyID uint32
+	Code    string
+}
+
+func (*SpectateMessage) Opcode() Opcode {
+	return OpcodeSpectate
+}
+
+func (m *SpectateMessage) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("spectate: data too short")
+	}
+
+	m.LobbyID = binary.BigEndian.Uint32(data[:4])
+
+	rest := data[4:]
+	if len(rest) == 0 {
+		return nil
+	}
+
+	codeLen := int(rest[0])
+	if len(rest) < 1+codeLen {
+		return fmt.Errorf("spectate: invalid code length")
+	}
+
+	m.Code = strings.ToUpper(string(rest[1 : 1+codeLen]))
+	return nil
+}
+
+// ---- Watch Replay (Opcode 10) ----
+// Speed multiplies playback, 0 and 1 both mean real time
+type WatchReplayMessage struct {
+	Speed byte
+	ID    string
+}
+
+func (*WatchReplayMessage) Opcode() Opcode {
+	return OpcodeWatchReplay
+}
+
+func (m *WatchReplayMessage) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("watch replay: data too short")
+	}
+
+	m.Speed = data[0]
+
+	idLen := int(data[1])
+	if len(data) != 2+idLen {
+		return fmt.Errorf("watch replay: invalid id length")
+	}
+
+	m.ID = string(data[2:])
+	return nil
+}
+
 func ParseClientMessage(buf []byte) (ClientMessage, error) {
 	if len(buf) < 1 {
 		return nil, fmt.Errorf("empty client message")
@@ -144,6 +354,24 @@ func ParseClientMessage(buf []byte) (ClientMessage, error) {
 	case OpcodeSelectPowerups:
 		msg = &SelectPowerupsMessage{}
 
+	case OpcodeCreateLobby:
+		msg = &CreateLobbyMessage{}
+
+	case OpcodeWordSubmission:
+		msg = &WordSubmissionMessage{}
+
+	case OpcodeKeystrokeReport:
+		msg = &KeystrokeReportMessage{}
+
+	case OpcodeResume:
+		msg = &ResumeMessage{}
+
+	case OpcodeSpectate:
+		msg = &SpectateMessage{}
+
+	case OpcodeWatchReplay:
+		msg = &WatchReplayMessage{}
+
 	default:
 		return nil, fmt.Errorf("unknown client opcode: %d", op)
 	}
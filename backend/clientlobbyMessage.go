@@ -13,12 +13,18 @@ type ClientLobbyProgressUpdate struct {
 	clientId byte
 	progress float32
 	wpm      int
+	rawWpm   int
+	accuracy float32
+	// words completed so far
+	words int
 }
 
 func (ClientLobbyProgressUpdate) clientLobbyMessage() {}
 
 type ClientLobbyFinished struct {
 	clientId byte
+	wpm      int
+	accuracy float32
 }
 
 func (ClientLobbyFinished) clientLobbyMessage() {}
@@ -27,17 +33,62 @@ type ClientLobbyApplyStatusEffect struct {
 	fromClientId     byte
 	affectedClientId byte
 	powerupId        byte
+	// sent back by a Reflector, nobody is waiting to hear if it landed
+	reflected bool
 }
 
 func (ClientLobbyApplyStatusEffect) clientLobbyMessage() {}
 
 type ClientLobbyStatusChanged struct {
-	clientId   byte
-	powerupIds []byte
+	clientId  byte
+	effects   []effectStatus
+	cooldowns []cooldownStatus
 }
 
 func (ClientLobbyStatusChanged) clientLobbyMessage() {}
 
+func (m ClientLobbyStatusChanged) powerupIds() []byte {
+	ids := make([]byte, 0, len(m.effects))
+	for _, e := range m.effects {
+		ids = append(ids, byte(e.id))
+	}
+	return ids
+}
+
+type ClientLobbyPowerupUsed struct {
+	clientId  byte
+	powerupId byte
+}
+
+func (ClientLobbyPowerupUsed) clientLobbyMessage() {}
+
+type ClientLobbyEffectReceived struct {
+	clientId     byte
+	fromClientId byte
+	powerupId    byte
+}
+
+func (ClientLobbyEffectReceived) clientLobbyMessage() {}
+
+// ClientLobbyEffectBlocked is a hit that a shield or mirror stopped, or that
+// never applied, so the client can be hit again
+type ClientLobbyEffectBlocked struct {
+	clientId byte
+}
+
+func (ClientLobbyEffectBlocked) clientLobbyMessage() {}
+
+// the client's state at the moment it resumed, for the lobby to resend
+type ClientLobbyResumed struct {
+	clientId byte
+	idx      int
+	words    []string
+	powerups []byte
+	purse    wallet
+}
+
+func (ClientLobbyResumed) clientLobbyMessage() {}
+
 type LobbyClientMessage interface {
 	lobbyClientMessage()
 }
@@ -48,3 +99,12 @@ type LobbyClientApplyStatusEffect struct {
 }
 
 func (LobbyClientApplyStatusEffect) lobbyClientMessage() {}
+
+// LobbyClientPurchaseLanded tells a buyer whether their powerup reached its
+// target, it doesn't if they're immune, a teammate or gone
+type LobbyClientPurchaseLanded struct {
+	powerupId byte
+	landed    bool
+}
+
+func (LobbyClientPurchaseLanded) lobbyClientMessage() {}
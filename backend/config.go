@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Config holds every tunable the server reads at startup. It is loaded once in
+// Serve from an optional JSON file, then environment overrides, and shared
+// read-only by the hub, lobbies and clients afterwards.
+type Config struct {
+	Port string `json:"port"`
+
+	ClientsPerLobby       int    `json:"clientsPerLobby"`
+	LobbyWait             uint16 `json:"lobbyWait"`
+	PrivateLobbyWait      uint16 `json:"privateLobbyWait"`
+	RaceTimeLimit         uint16 `json:"raceTimeLimit"`
+	WordCount             int    `json:"wordCount"`
+	DisplayedPowerupCount int    `json:"displayedPowerupCount"`
+	AllowedPowerupCount   int    `json:"allowedPowerupCount"`
+
+	// seconds a dropped player's place is held for them to resume, 0 to
+	// disable resuming
+	ResumeGrace uint16 `json:"resumeGrace"`
+
+	// where finished races are saved for playback, empty to not save them
+	ReplayDir string `json:"replayDir"`
+
+	// where player profiles and race history are kept, empty to only keep
+	// them in memory
+	DataDir string `json:"dataDir"`
+
+	// where uploaded word lists are kept, empty to not take uploads
+	WordListDir string `json:"wordListDir"`
+
+	// public players are matched with others within MatchmakingTolerance
+	// rating points, widening by MatchmakingWiden every second they wait, until
+	// after MatchmakingMaxWait seconds they take whoever is around
+	MatchmakingTolerance int    `json:"matchmakingTolerance"`
+	MatchmakingWiden     int    `json:"matchmakingWiden"`
+	MatchmakingMaxWait   uint16 `json:"matchmakingMaxWait"`
+
+	// public lobbies still short of players when the wait runs out take up to
+	// BotSeats bots, 0 to race with whoever showed up. Each bot types at a
+	// speed and accuracy (a percentage) picked from these ranges.
+	BotSeats       int `json:"botSeats"`
+	BotMinWpm      int `json:"botMinWpm"`
+	BotMaxWpm      int `json:"botMaxWpm"`
+	BotMinAccuracy int `json:"botMinAccuracy"`
+	BotMaxAccuracy int `json:"botMaxAccuracy"`
+
+	Powerups PowerupConfig `json:"powerups"`
+}
+
+// durations are in seconds, everything else is a word count
+type PowerupConfig struct {
+	FogDuration            int `json:"fogDuration"`
+	TireBootDuration       int `json:"tireBootDuration"`
+	RearViewMirrorDuration int `json:"rearViewMirrorDuration"`
+	ShieldDuration         int `json:"shieldDuration"`
+	NitroDuration          int `json:"nitroDuration"`
+	// percent more progress for every word typed under nitro
+	NitroBoost int `json:"nitroBoost"`
+
+	SpikeStripWordsAdded int `json:"spikeStripWordsAdded"`
+	WordsScrambled       int `json:"wordsScrambled"`
+	WordsIced            int `json:"wordsIced"`
+	WordsStickShifted    int `json:"wordsStickShifted"`
+	WordsReversed        int `json:"wordsReversed"`
+	WordsVowelsDropped   int `json:"wordsVowelsDropped"`
+	WordsHomoglyphed     int `json:"wordsHomoglyphed"`
+	WordsCapitalized     int `json:"wordsCapitalized"`
+	// merged in pairs, so half as many words to type
+	WordsMerged        int `json:"wordsMerged"`
+	WordsAutocorrected int `json:"wordsAutocorrected"`
+
+	Offset int `json:"offset"`
+
+	// seconds a racer can't be hit again after being hit, and seconds before
+	// a buyer can use the same powerup again
+	Immunity int `json:"immunity"`
+	Cooldown int `json:"cooldown"`
+
+	// coins for every accepted word, and StreakBonus more every StreakLength
+	// words in a row without a mistake
+	WordReward   int `json:"wordReward"`
+	StreakLength int `json:"streakLength"`
+	StreakBonus  int `json:"streakBonus"`
+
+	// what each powerup costs in coins, by name
+	Costs map[string]int `json:"costs"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Port: "8080",
+
+		ClientsPerLobby:       4,
+		LobbyWait:             25,
+		PrivateLobbyWait:      120,
+		RaceTimeLimit:         180,
+		WordCount:             50,
+		DisplayedPowerupCount: 4,
+		AllowedPowerupCount:   2,
+
+		ResumeGrace: 30,
+
+		ReplayDir: "replays",
+		DataDir:   "data",
+
+		WordListDir: "wordlists",
+
+		MatchmakingTolerance: 100,
+		MatchmakingWiden:     25,
+		MatchmakingMaxWait:   20,
+
+		BotSeats:       3,
+		BotMinWpm:      35,
+		BotMaxWpm:      85,
+		BotMinAccuracy: 90,
+		BotMaxAccuracy: 98,
+
+		Powerups: PowerupConfig{
+			FogDuration:            10,
+			TireBootDuration:       10,
+			RearViewMirrorDuration: 10,
+			ShieldDuration:         15,
+			NitroDuration:          8,
+			NitroBoost:             50,
+
+			SpikeStripWordsAdded: 5,
+			WordsScrambled:       10,
+			WordsIced:            10,
+			WordsStickShifted:    10,
+			WordsReversed:        8,
+			WordsVowelsDropped:   10,
+			WordsHomoglyphed:     10,
+			WordsCapitalized:     10,
+			WordsMerged:          10,
+			WordsAutocorrected:   3,
+
+			Offset: 3,
+
+			Immunity: 2,
+			Cooldown: 5,
+
+			WordReward:   1,
+			StreakLength: 10,
+			StreakBonus:  5,
+
+			Costs: defaultPowerupCosts(),
+		},
+	}
+}
+
+// LoadConfig starts from the defaults, applies the file at path if one is
+// given, then any environment overrides, and validates the result
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("config: parsing %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) envOverrides() map[string]any {
+	return map[string]any{
+		"PORT": &c.Port,
+
+		"OVERTYPED_CLIENTS_PER_LOBBY":         &c.ClientsPerLobby,
+		"OVERTYPED_LOBBY_WAIT":                &c.LobbyWait,
+		"OVERTYPED_PRIVATE_LOBBY_WAIT":        &c.PrivateLobbyWait,
+		"OVERTYPED_RACE_TIME_LIMIT":           &c.RaceTimeLimit,
+		"OVERTYPED_WORD_COUNT":                &c.WordCount,
+		"OVERTYPED_DISPLAYED_POWERUP_COUNT":   &c.DisplayedPowerupCount,
+		"OVERTYPED_ALLOWED_POWERUP_COUNT":     &c.AllowedPowerupCount,
+		"OVERTYPED_RESUME_GRACE":              &c.ResumeGrace,
+		"OVERTYPED_REPLAY_DIR":                &c.ReplayDir,
+		"OVERTYPED_DATA_DIR":                  &c.DataDir,
+		"OVERTYPED_WORD_LIST_DIR":             &c.WordListDir,
+		"OVERTYPED_MATCHMAKING_TOLERANCE":     &c.MatchmakingTolerance,
+		"OVERTYPED_MATCHMAKING_WIDEN":         &c.MatchmakingWiden,
+		"OVERTYPED_MATCHMAKING_MAX_WAIT":      &c.MatchmakingMaxWait,
+		"OVERTYPED_BOT_SEATS":                 &c.BotSeats,
+		"OVERTYPED_BOT_MIN_WPM":               &c.BotMinWpm,
+		"OVERTYPED_BOT_MAX_WPM":               &c.BotMaxWpm,
+		"OVERTYPED_BOT_MIN_ACCURACY":          &c.BotMinAccuracy,
+		"OVERTYPED_BOT_MAX_ACCURACY":          &c.BotMaxAccuracy,
+		"OVERTYPED_FOG_DURATION":              &c.Powerups.FogDuration,
+		"OVERTYPED_TIRE_BOOT_DURATION":        &c.Powerups.TireBootDuration,
+		"OVERTYPED_REAR_VIEW_MIRROR_DURATION": &c.Powerups.RearViewMirrorDuration,
+		"OVERTYPED_SHIELD_DURATION":           &c.Powerups.ShieldDuration,
+		"OVERTYPED_NITRO_DURATION":            &c.Powerups.NitroDuration,
+		"OVERTYPED_NITRO_BOOST":               &c.Powerups.NitroBoost,
+		"OVERTYPED_WORDS_AUTOCORRECTED":       &c.Powerups.WordsAutocorrected,
+		"OVERTYPED_SPIKE_STRIP_WORDS_ADDED":   &c.Powerups.SpikeStripWordsAdded,
+		"OVERTYPED_WORDS_SCRAMBLED":           &c.Powerups.WordsScrambled,
+		"OVERTYPED_WORDS_ICED":                &c.Powerups.WordsIced,
+		"OVERTYPED_WORDS_STICK_SHIFTED":       &c.Powerups.WordsStickShifted,
+		"OVERTYPED_WORDS_REVERSED":            &c.Powerups.WordsReversed,
+		"OVERTYPED_WORDS_VOWELS_DROPPED":      &c.Powerups.WordsVowelsDropped,
+		"OVERTYPED_WORDS_HOMOGLYPHED":         &c.Powerups.WordsHomoglyphed,
+		"OVERTYPED_WORDS_CAPITALIZED":         &c.Powerups.WordsCapitalized,
+		"OVERTYPED_WORDS_MERGED":              &c.Powerups.WordsMerged,
+		"OVERTYPED_POWERUP_OFFSET":            &c.Powerups.Offset,
+		"OVERTYPED_POWERUP_IMMUNITY":          &c.Powerups.Immunity,
+		"OVERTYPED_POWERUP_COOLDOWN":          &c.Powerups.Cooldown,
+		"OVERTYPED_WORD_REWARD":               &c.Powerups.WordReward,
+		"OVERTYPED_STREAK_LENGTH":             &c.Powerups.StreakLength,
+		"OVERTYPED_STREAK_BONUS":              &c.Powerups.StreakBonus,
+	}
+}
+
+func (c *Config) applyEnv() error {
+	for name, field := range c.envOverrides() {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			continue
+		}
+
+		switch field := field.(type) {
+		case *string:
+			*field = value
+
+		case *int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("config: %s: %w", name, err)
+			}
+			*field = n
+
+		case *uint16:
+			n, err := strconv.ParseUint(value, 10, 16)
+			if err != nil {
+				return fmt.Errorf("config: %s: %w", name, err)
+			}
+			*field = uint16(n)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, v ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("config: "+format, v...))
+		}
+	}
+
+	check(c.Port != "", "port must be set")
+
+	// player ids are a single byte
+	check(c.ClientsPerLobby >= 1 && c.ClientsPerLobby <= 255,
+		"clientsPerLobby must be between 1 and 255, got %d", c.ClientsPerLobby)
+	check(c.LobbyWait > lobbyCloseLead,
+		"lobbyWait must be more than %d seconds, got %d", lobbyCloseLead, c.LobbyWait)
+	check(c.PrivateLobbyWait > lobbyCloseLead,
+		"privateLobbyWait must be more than %d seconds, got %d", lobbyCloseLead, c.PrivateLobbyWait)
+	check(c.RaceTimeLimit > 0, "raceTimeLimit must be positive")
+	check(c.WordCount > 0, "wordCount must be positive, got %d", c.WordCount)
+	check(c.DisplayedPowerupCount >= 0 && c.DisplayedPowerupCount <= int(PowerupCount),
+		"displayedPowerupCount must be between 0 and %d, got %d", PowerupCount, c.DisplayedPowerupCount)
+	check(c.AllowedPowerupCount >= 0 && c.AllowedPowerupCount <= c.DisplayedPowerupCount,
+		"allowedPowerupCount must be between 0 and displayedPowerupCount, got %d", c.AllowedPowerupCount)
+	check(c.MatchmakingTolerance >= 0, "matchmakingTolerance must not be negative")
+	check(c.MatchmakingWiden >= 0, "matchmakingWiden must not be negative")
+	check(c.BotSeats >= 0, "botSeats must not be negative")
+	check(c.BotMinWpm > 0 && c.BotMinWpm <= c.BotMaxWpm,
+		"botMinWpm must be positive and at most botMaxWpm, got %d and %d", c.BotMinWpm, c.BotMaxWpm)
+	check(c.BotMinAccuracy > 50 && c.BotMinAccuracy <= c.BotMaxAccuracy && c.BotMaxAccuracy <= 100,
+		"bot accuracy must be between 50 and 100 percent, got %d to %d", c.BotMinAccuracy, c.BotMaxAccuracy)
+
+	p := c.Powerups
+	check(p.FogDuration > 0, "powerups.fogDuration must be positive")
+	check(p.TireBootDuration > 0, "powerups.tireBootDuration must be positive")
+	check(p.RearViewMirrorDuration > 0, "powerups.rearViewMirrorDuration must be positive")
+	check(p.ShieldDuration > 0, "powerups.shieldDuration must be positive")
+	check(p.NitroDuration > 0, "powerups.nitroDuration must be positive")
+	check(p.NitroBoost > 0, "powerups.nitroBoost must be positive")
+	check(p.WordsAutocorrected > 0, "powerups.wordsAutocorrected must be positive")
+	check(p.SpikeStripWordsAdded > 0, "powerups.spikeStripWordsAdded must be positive")
+	check(p.WordsScrambled > 0, "powerups.wordsScrambled must be positive")
+	check(p.WordsIced > 0, "powerups.wordsIced must be positive")
+	check(p.WordsStickShifted > 0, "powerups.wordsStickShifted must be positive")
+	check(p.WordsReversed > 0, "powerups.wordsReversed must be positive")
+	check(p.WordsVowelsDropped > 0, "powerups.wordsVowelsDropped must be positive")
+	check(p.WordsHomoglyphed > 0, "powerups.wordsHomoglyphed must be positive")
+	check(p.WordsCapitalized > 0, "powerups.wordsCapitalized must be positive")
+	check(p.WordsMerged > 1, "powerups.wordsMerged must be at least 2")
+	check(p.Offset >= 0, "powerups.offset must not be negative")
+	check(p.Immunity >= 0, "powerups.immunity must not be negative")
+	check(p.Cooldown >= 0, "powerups.cooldown must not be negative")
+	check(p.WordReward >= 0, "powerups.wordReward must not be negative")
+	check(p.StreakLength > 0, "powerups.streakLength must be positive")
+	check(p.StreakBonus >= 0, "powerups.streakBonus must not be negative")
+	if err := p.validateCosts(); err != nil {
+		errs = append(errs, fmt.Errorf("config: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) Dump() ([]byte, error) {
+	return json.MarshalIndent(c, "", "\t")
+}
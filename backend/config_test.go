@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestDefaultConfigValidates(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("default config doesn't validate: %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		edit  func(c *Config)
+		valid bool
+	}{
+		{"no port", func(c *Config) { c.Port = "" }, false},
+		{"no clients", func(c *Config) { c.ClientsPerLobby = 0 }, false},
+		{"one client", func(c *Config) { c.ClientsPerLobby = 1 }, true},
+		{"255 clients", func(c *Config) { c.ClientsPerLobby = 255 }, true},
+		// player ids are a single byte
+		{"256 clients", func(c *Config) { c.ClientsPerLobby = 256 }, false},
+		{"wait at close lead", func(c *Config) { c.LobbyWait = lobbyCloseLead }, false},
+		{"private wait at close lead", func(c *Config) { c.PrivateLobbyWait = lobbyCloseLead }, false},
+		{"no time limit", func(c *Config) { c.RaceTimeLimit = 0 }, false},
+		{"no words", func(c *Config) { c.WordCount = 0 }, false},
+		{"too many displayed", func(c *Config) { c.DisplayedPowerupCount = int(PowerupCount) + 1 }, false},
+		{"more allowed than displayed", func(c *Config) { c.AllowedPowerupCount = c.DisplayedPowerupCount + 1 }, false},
+		{"no bot seats", func(c *Config) { c.BotSeats = 0 }, true},
+		{"negative bot seats", func(c *Config) { c.BotSeats = -1 }, false},
+		{"bot wpm backwards", func(c *Config) { c.BotMinWpm, c.BotMaxWpm = 80, 40 }, false},
+		{"bot accuracy over 100", func(c *Config) { c.BotMaxAccuracy = 101 }, false},
+		{"bot accuracy at 50", func(c *Config) { c.BotMinAccuracy = 50 }, false},
+		{"no fog", func(c *Config) { c.Powerups.FogDuration = 0 }, false},
+		{"merging one word", func(c *Config) { c.Powerups.WordsMerged = 1 }, false},
+		{"no immunity", func(c *Config) { c.Powerups.Immunity = 0 }, true},
+		{"negative cooldown", func(c *Config) { c.Powerups.Cooldown = -1 }, false},
+		{"no streaks", func(c *Config) { c.Powerups.StreakLength = 0 }, false},
+		{"free powerup", func(c *Config) { c.Powerups.Costs[PowerupShield.String()] = 0 }, false},
+		{"costly powerup", func(c *Config) { c.Powerups.Costs[PowerupShield.String()] = 0x10000 }, false},
+		{"missing cost", func(c *Config) { delete(c.Powerups.Costs, PowerupShield.String()) }, false},
+		{"unknown cost", func(c *Config) { c.Powerups.Costs["jetpack"] = 5 }, false},
+	}
+
+	for _, tt := range tests {
+		c := DefaultConfig()
+		tt.edit(c)
+
+		if err := c.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
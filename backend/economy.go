@@ -0,0 +1,96 @@
+package main
+
+import "fmt"
+
+// ---- Economy ----
+//
+// Powerups cost coins earned during the race: WordReward for every accepted
+// word, plus StreakBonus every StreakLength words in a row without a
+// mistake. A selected powerup can be bought as often as the player can
+// afford it.
+
+// defaultPowerupCosts are each registered powerup's own price
+func defaultPowerupCosts() map[string]int {
+	costs := make(map[string]int, len(powerupRegistry))
+	for _, p := range powerupRegistry {
+		if p != nil {
+			costs[p.Name()] = p.DefaultCost()
+		}
+	}
+	return costs
+}
+
+func (pc PowerupConfig) cost(p PowerupId) int {
+	return pc.Costs[p.String()]
+}
+
+// validateCosts wants a positive cost for every powerup and nothing else
+func (pc PowerupConfig) validateCosts() error {
+	for name := range pc.Costs {
+		if !isPowerupName(name) {
+			return fmt.Errorf("powerups.costs: unknown powerup %q", name)
+		}
+	}
+	for p := range PowerupCount {
+		if cost, ok := pc.Costs[p.String()]; !ok || cost <= 0 || cost > 0xffff {
+			return fmt.Errorf("powerups.costs: %s must cost between 1 and %d", p, 0xffff)
+		}
+	}
+	return nil
+}
+
+func isPowerupName(name string) bool {
+	for _, p := range powerupRegistry {
+		if p != nil && p.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func powerupCostsMessage(pc PowerupConfig) PowerupCostsMessage {
+	msg := PowerupCostsMessage{}
+	for p := range PowerupCount {
+		msg.Costs = append(msg.Costs, PowerupCost{
+			PowerupID: byte(p),
+			Cost:      uint16(pc.cost(p)),
+		})
+	}
+	return msg
+}
+
+// wallet is a racer's coins, kept by their state handler
+type wallet struct {
+	balance int
+	streak  int
+}
+
+func (w *wallet) earn(pc PowerupConfig) {
+	w.balance += pc.WordReward
+	w.streak++
+	if w.streak%pc.StreakLength == 0 {
+		w.balance += pc.StreakBonus
+	}
+}
+
+// breakStreak reports whether there was a streak to break
+func (w *wallet) breakStreak() bool {
+	broke := w.streak > 0
+	w.streak = 0
+	return broke
+}
+
+func (w *wallet) spend(cost int) bool {
+	if w.balance < cost {
+		return false
+	}
+	w.balance -= cost
+	return true
+}
+
+func (w wallet) message() BalanceMessage {
+	return BalanceMessage{
+		Balance: uint32(w.balance),
+		Streak:  uint16(min(w.streak, 0xffff)),
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+// ---- Effects ----
+//
+// The powerups themselves. Adding one is a PowerupId, a type here and its
+// registration; the state handler picks up the rest from the interfaces it
+// implements.
+
+func init() {
+	registerPowerup(spikeStrip{})
+	registerPowerup(stickShift{})
+	registerPowerup(fog{})
+	registerPowerup(icyRoads{})
+	registerPowerup(tireBoot{})
+	registerPowerup(scrambler{})
+	registerPowerup(rearViewMirror{})
+	registerPowerup(reverseGear{})
+	registerPowerup(potholes{})
+	registerPowerup(mirage{})
+	registerPowerup(bumpyRoad{})
+	registerPowerup(tailgate{})
+	registerPowerup(shield{})
+	registerPowerup(nitro{})
+	registerPowerup(cleanse{})
+	registerPowerup(autocorrect{})
+}
+
+// spikeStrip adds words to the end of the target's race, more for every hit
+type spikeStrip struct{}
+
+func (spikeStrip) Id() PowerupId    { return PowerupSpikeStrip }
+func (spikeStrip) Name() string     { return "spikeStrip" }
+func (spikeStrip) DefaultCost() int { return 10 }
+
+func (spikeStrip) Duration(pc PowerupConfig) EffectDuration {
+	return wordsEffect(pc.SpikeStripWordsAdded)
+}
+
+func (spikeStrip) Transform(t *effectTarget, words []string, start int) []string {
+	return t.addWords(words, t.c.wordSource.Words(t.cfg().SpikeStripWordsAdded))
+}
+
+func (spikeStrip) Stacking() StackRule {
+	return StackRule{Mode: StackIntensity, MaxIntensity: 3}
+}
+
+// stickShift obfuscates the next few words
+type stickShift struct{}
+
+func (stickShift) Id() PowerupId    { return PowerupStickShift }
+func (stickShift) Name() string     { return "stickShift" }
+func (stickShift) DefaultCost() int { return 12 }
+
+func (stickShift) Duration(pc PowerupConfig) EffectDuration {
+	return wordsEffect(pc.WordsStickShifted)
+}
+
+func (stickShift) Transform(t *effectTarget, words []string, start int) []string {
+	return ObfuscateRange(words, start, t.cfg().WordsStickShifted)
+}
+
+// fog is drawn by the client over the target's words, another hit makes it
+// last longer
+type fog struct{}
+
+func (fog) Id() PowerupId    { return PowerupFog }
+func (fog) Name() string     { return "fog" }
+func (fog) DefaultCost() int { return 15 }
+
+func (fog) Duration(pc PowerupConfig) EffectDuration {
+	return timedEffect(pc.FogDuration)
+}
+
+func (fog) Stacking() StackRule {
+	return StackRule{Mode: StackExtend}
+}
+
+// icyRoads repeats letters in the next few words, twice as many when hit twice
+type icyRoads struct{}
+
+func (icyRoads) Id() PowerupId    { return PowerupIcyRoads }
+func (icyRoads) Name() string     { return "icyRoads" }
+func (icyRoads) DefaultCost() int { return 12 }
+
+func (icyRoads) Duration(pc PowerupConfig) EffectDuration {
+	return wordsEffect(pc.WordsIced)
+}
+
+func (icyRoads) Transform(t *effectTarget, words []string, start int) []string {
+	return RepeatCharsRange(words, start, t.cfg().WordsIced)
+}
+
+func (icyRoads) Stacking() StackRule {
+	return StackRule{Mode: StackIntensity, MaxIntensity: 2}
+}
+
+// tireBoot is drawn by the client, which holds the target back, another hit
+// makes it last longer
+type tireBoot struct{}
+
+func (tireBoot) Id() PowerupId    { return PowerupTireBoot }
+func (tireBoot) Name() string     { return "tireBoot" }
+func (tireBoot) DefaultCost() int { return 15 }
+
+func (tireBoot) Duration(pc PowerupConfig) EffectDuration {
+	return timedEffect(pc.TireBootDuration)
+}
+
+func (tireBoot) Stacking() StackRule {
+	return StackRule{Mode: StackExtend}
+}
+
+// scrambler shuffles the letters of the next few words
+type scrambler struct{}
+
+func (scrambler) Id() PowerupId    { return PowerupScrambler }
+func (scrambler) Name() string     { return "scrambler" }
+func (scrambler) DefaultCost() int { return 12 }
+
+func (scrambler) Duration(pc PowerupConfig) EffectDuration {
+	return wordsEffect(pc.WordsScrambled)
+}
+
+func (scrambler) Transform(t *effectTarget, words []string, start int) []string {
+	return ScrambleRange(words, start, t.cfg().WordsScrambled)
+}
+
+// rearViewMirror sends the next powerup aimed at its buyer back
+type rearViewMirror struct{}
+
+func (rearViewMirror) Id() PowerupId    { return PowerupRearViewMirror }
+func (rearViewMirror) Name() string     { return "rearViewMirror" }
+func (rearViewMirror) DefaultCost() int { return 20 }
+func (rearViewMirror) TargetsSelf()     {}
+
+func (rearViewMirror) Duration(pc PowerupConfig) EffectDuration {
+	return timedEffect(pc.RearViewMirrorDuration)
+}
+
+func (rearViewMirror) Reflects(incoming Powerup) bool {
+	return true
+}
+
+// reverseGear spells the next few words backwards
+type reverseGear struct{}
+
+func (reverseGear) Id() PowerupId    { return PowerupReverseGear }
+func (reverseGear) Name() string     { return "reverseGear" }
+func (reverseGear) DefaultCost() int { return 12 }
+
+func (reverseGear) Duration(pc PowerupConfig) EffectDuration {
+	return wordsEffect(pc.WordsReversed)
+}
+
+func (reverseGear) Transform(t *effectTarget, words []string, start int) []string {
+	return ReverseRange(words, start, t.cfg().WordsReversed)
+}
+
+// reversing words again would put them back, so another hit only starts it
+// over
+func (reverseGear) Stacking() StackRule {
+	return StackRule{Mode: StackIntensity, MaxIntensity: 1}
+}
+
+// potholes drops the vowels from the next few words
+type potholes struct{}
+
+func (potholes) Id() PowerupId    { return PowerupPotholes }
+func (potholes) Name() string     { return "potholes" }
+func (potholes) DefaultCost() int { return 10 }
+
+func (potholes) Duration(pc PowerupConfig) EffectDuration {
+	return wordsEffect(pc.WordsVowelsDropped)
+}
+
+func (potholes) Transform(t *effectTarget, words []string, start int) []string {
+	return DropVowelsRange(words, start, t.cfg().WordsVowelsDropped)
+}
+
+// mirage swaps letters in the next few words for lookalikes
+type mirage struct{}
+
+func (mirage) Id() PowerupId    { return PowerupMirage }
+func (mirage) Name() string     { return "mirage" }
+func (mirage) DefaultCost() int { return 12 }
+
+func (mirage) Duration(pc PowerupConfig) EffectDuration {
+	return wordsEffect(pc.WordsHomoglyphed)
+}
+
+func (mirage) Transform(t *effectTarget, words []string, start int) []string {
+	return HomoglyphRange(words, start, t.cfg().WordsHomoglyphed)
+}
+
+// bumpyRoad randomly capitalizes letters in the next few words
+type bumpyRoad struct{}
+
+func (bumpyRoad) Id() PowerupId    { return PowerupBumpyRoad }
+func (bumpyRoad) Name() string     { return "bumpyRoad" }
+func (bumpyRoad) DefaultCost() int { return 10 }
+
+func (bumpyRoad) Duration(pc PowerupConfig) EffectDuration {
+	return wordsEffect(pc.WordsCapitalized)
+}
+
+func (bumpyRoad) Transform(t *effectTarget, words []string, start int) []string {
+	return RandomCapsRange(words, start, t.cfg().WordsCapitalized)
+}
+
+// tailgate runs the next few words together in pairs, twice over when hit
+// twice
+type tailgate struct{}
+
+func (tailgate) Id() PowerupId    { return PowerupTailgate }
+func (tailgate) Name() string     { return "tailgate" }
+func (tailgate) DefaultCost() int { return 15 }
+
+func (tailgate) Duration(pc PowerupConfig) EffectDuration {
+	return wordsEffect(pc.WordsMerged / 2)
+}
+
+func (tailgate) Transform(t *effectTarget, words []string, start int) []string {
+	t.mergePlain(start, t.cfg().WordsMerged)
+	return MergeRange(words, start, t.cfg().WordsMerged)
+}
+
+func (tailgate) Stacking() StackRule {
+	return StackRule{Mode: StackIntensity, MaxIntensity: 2}
+}
+
+// shield stops the next powerup aimed at its buyer
+type shield struct{}
+
+func (shield) Id() PowerupId    { return PowerupShield }
+func (shield) Name() string     { return "shield" }
+func (shield) DefaultCost() int { return 15 }
+func (shield) TargetsSelf()     {}
+
+func (shield) Duration(pc PowerupConfig) EffectDuration {
+	return timedEffect(pc.ShieldDuration)
+}
+
+func (shield) Absorbs(incoming Powerup) bool {
+	return true
+}
+
+// nitro counts every word its buyer types for more progress for a while,
+// longer when bought again
+type nitro struct{}
+
+func (nitro) Id() PowerupId    { return PowerupNitro }
+func (nitro) Name() string     { return "nitro" }
+func (nitro) DefaultCost() int { return 20 }
+func (nitro) TargetsSelf()     {}
+
+func (nitro) Duration(pc PowerupConfig) EffectDuration {
+	return timedEffect(pc.NitroDuration)
+}
+
+func (nitro) ProgressMultiplier(pc PowerupConfig) float64 {
+	return 1 + float64(pc.NitroBoost)/100
+}
+
+func (nitro) Stacking() StackRule {
+	return StackRule{Mode: StackExtend}
+}
+
+// cleanse takes off everything opponents put on its buyer and puts their
+// words back the way they were
+type cleanse struct{}
+
+func (cleanse) Id() PowerupId    { return PowerupCleanse }
+func (cleanse) Name() string     { return "cleanse" }
+func (cleanse) DefaultCost() int { return 15 }
+func (cleanse) TargetsSelf()     {}
+
+func (cleanse) Duration(pc PowerupConfig) EffectDuration {
+	return instantEffect()
+}
+
+func (cleanse) Apply(t *effectTarget) {
+	t.removeDebuffs()
+	t.restoreWords()
+}
+
+// autocorrect types the next few words for its buyer. They count for
+// progress but not wpm, which is only what was actually typed.
+type autocorrect struct{}
+
+func (autocorrect) Id() PowerupId    { return PowerupAutocorrect }
+func (autocorrect) Name() string     { return "autocorrect" }
+func (autocorrect) DefaultCost() int { return 20 }
+func (autocorrect) TargetsSelf()     {}
+
+func (autocorrect) Duration(pc PowerupConfig) EffectDuration {
+	return instantEffect()
+}
+
+func (autocorrect) Apply(t *effectTarget) {
+	t.skip += t.cfg().WordsAutocorrected
+}
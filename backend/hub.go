@@ -1,39 +1,182 @@
 package main
 
 import (
+	"errors"
 	"log"
+	"math/rand"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 var lId int = 0
 
+const lobbyCodeLength = 6
+const lobbyCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
 type Hub struct {
+	cfg *Config
+
 	registerClientQueue chan *Client
+	createLobbyQueue    chan *Client
+	joinLobbyQueue      chan *Client
+	spectateQueue       chan *Client
+	heatQueue           chan *Heat
+	closedLobbies       chan *Lobby
+
+	// public players waiting for a match, oldest first
+	queue   []queuedClient
+	forming []*formingLobby
+
+	// private lobbies keyed by join code
+	lobbies map[string]*Lobby
+	// every running lobby, public or private
+	active map[int]*Lobby
 
-	lobby *Lobby
+	sessions *sessionRegistry
+	replays  replayStore
+	words    *wordSources
+	store    Store
+
+	leaderboards *Leaderboards
+	ratings      *Ratings
+	tournaments  *Tournaments
 }
 
-func NewHub() *Hub {
-	return &Hub{
+func NewHub(cfg *Config, store Store, words *wordSources) *Hub {
+	h := &Hub{
+		cfg: cfg,
+
 		registerClientQueue: make(chan *Client),
+		createLobbyQueue:    make(chan *Client),
+		joinLobbyQueue:      make(chan *Client),
+		spectateQueue:       make(chan *Client),
+		heatQueue:           make(chan *Heat),
+		closedLobbies:       make(chan *Lobby),
+
+		lobbies: make(map[string]*Lobby),
+		active:  make(map[int]*Lobby),
+
+		sessions: newSessionRegistry(),
+		replays:  replayStore{dir: cfg.ReplayDir},
+		words:    words,
+		store:    store,
+
+		leaderboards: NewLeaderboards(store.Races()),
+		ratings:      NewRatings(store.Races()),
 	}
+	h.tournaments = newTournaments(h)
+	return h
 }
 
 func (h *Hub) Run() {
 	log.Println("Hub Started")
 
-	for client := range h.registerClientQueue {
-		log.Println("Client Recieved in Hub")
+	matchTicker := time.NewTicker(matchmakingInterval)
+	defer matchTicker.Stop()
+
+	for {
+		select {
+		case client := <-h.registerClientQueue:
+			log.Println("Client Recieved in Hub")
 
-		if h.lobby == nil || !h.lobby.open {
-			h.lobby = newLobby(lId, h)
-			go h.lobby.run()
+			h.enqueue(client)
+			h.matchmake()
+
+		case <-matchTicker.C:
+			h.matchmake()
+
+		case client := <-h.createLobbyQueue:
+			l := newPrivateLobby(lId, h, h.newLobbyCode(), client.create)
+			go l.run()
 			lId++
+
+			h.lobbies[l.code] = l
+			h.active[l.id] = l
+			log.Printf("Private lobby %d created with code %s", l.id, l.code)
+
+			h.joinPrivateLobby(l, client)
+
+		case heat := <-h.heatQueue:
+			t := heat.tournament
+			// checked when the tournament was made, but uploads can change
+			source, err := h.words.resolve(t.Source, t.Language)
+			if err != nil {
+				log.Printf("tournament %d: %s, racing the default words", t.ID, err)
+				source = h.words.defaultSource()
+			}
+
+			l := newPrivateLobby(lId, h, h.newLobbyCode(), lobbyOptions{source: source})
+			l.heat = heat
+			go l.run()
+			lId++
+
+			h.lobbies[l.code] = l
+			h.active[l.id] = l
+			log.Printf("Tournament %d round %d heat %d is lobby %d with code %s",
+				t.ID, heat.Round, heat.Index, l.id, l.code)
+
+			h.tournaments.heatOpened(heat, l)
+
+		case client := <-h.joinLobbyQueue:
+			l, ok := h.lobbies[client.joinCode]
+			if !ok {
+				client.log("no lobby with code %s", client.joinCode)
+				client.reject(JoinFailedNotFound)
+				continue
+			}
+
+			h.joinPrivateLobby(l, client)
+
+		case client := <-h.spectateQueue:
+			// ids are handed out in order, so a private lobby takes its code
+			l, ok := h.lobbies[client.joinCode]
+			if client.joinCode == "" {
+				l, ok = h.active[client.watchLobbyId]
+				ok = ok && !l.private
+			}
+			if !ok {
+				client.log("no lobby to spectate")
+				client.reject(JoinFailedNotFound)
+				continue
+			}
+
+			select {
+			case l.watch <- client:
+			case <-l.done:
+				client.reject(JoinFailedStarted)
+			}
+
+		case l := <-h.closedLobbies:
+			delete(h.active, l.id)
+			if l.private {
+				delete(h.lobbies, l.code)
+			}
+		}
+	}
+}
+
+// private lobbies outlive the public queue, so they may already be closing
+// by the time a client tries to join
+func (h *Hub) joinPrivateLobby(l *Lobby, c *Client) {
+	select {
+	case l.register <- c:
+	case <-l.done:
+		c.reject(JoinFailedStarted)
+	}
+}
+
+func (h *Hub) newLobbyCode() string {
+	for {
+		code := make([]byte, lobbyCodeLength)
+		for i := range code {
+			code[i] = lobbyCodeAlphabet[rand.Intn(len(lobbyCodeAlphabet))]
 		}
 
-		h.lobby.register <- client
+		if _, taken := h.lobbies[string(code)]; !taken {
+			return string(code)
+		}
 	}
 }
 
@@ -51,12 +194,7 @@ func (h *Hub) ServeWs(w http.ResponseWriter, r *http.Request) {
 
 	log.Println("New WS Connection")
 
-	c := &Client{
-		conn: conn,
-
-		lobbyWrite:    make(chan ServerMessage),
-		lobbyMsgWrite: make(chan LobbyClientMessage),
-	}
+	c := newClient(conn, h)
 
 	_, message, err := c.conn.ReadMessage()
 
@@ -72,11 +210,35 @@ func (h *Hub) ServeWs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if registerMessage, ok := clientMessage.(*RegisterMessage); ok {
-		c.name = registerMessage.Name
-	} else {
-		c.log("incorret first message received from client, expected %d, got %d",
-			OpcodeRegister, clientMessage.Opcode())
+	createLobby := false
+	spectate := false
+	var source, language string
+	var timeAttack uint16
+	var teams byte
+
+	switch msg := clientMessage.(type) {
+	case *RegisterMessage:
+		c.name = msg.Name
+		c.joinCode = msg.Code
+	case *CreateLobbyMessage:
+		c.name = msg.Name
+		source, language = msg.Source, msg.Language
+		timeAttack, teams = msg.TimeAttack, msg.Teams
+		createLobby = true
+	case *ResumeMessage:
+		h.resumeClient(conn, msg.Token)
+		return
+	case *WatchReplayMessage:
+		h.replays.playReplay(conn, msg)
+		return
+	case *SpectateMessage:
+		c.joinCode = msg.Code
+		c.watchLobbyId = int(msg.LobbyID)
+		spectate = true
+	default:
+		c.log("incorret first message received from client, expected %d, %d, %d, %d or %d, got %d",
+			OpcodeRegister, OpcodeCreateLobby, OpcodeResume, OpcodeSpectate, OpcodeWatchReplay,
+			clientMessage.Opcode())
 		return
 	}
 
@@ -86,5 +248,52 @@ func (h *Hub) ServeWs(w http.ResponseWriter, r *http.Request) {
 
 	c.lobbyWrite <- HubGreetingMessage{}
 
-	h.registerClientQueue <- c
+	switch {
+	case spectate:
+		h.spectateQueue <- c
+	case createLobby:
+		words, err := h.words.resolve(source, language)
+		if err != nil {
+			c.log("creating lobby: %s", err)
+			c.reject(JoinFailedWordSource)
+			return
+		}
+		if err := errors.Join(validateTimeAttack(timeAttack, words), validateTeams(teams, h.cfg)); err != nil {
+			c.log("creating lobby: %s", err)
+			c.reject(JoinFailedMode)
+			return
+		}
+		c.create = lobbyOptions{source: words, timeAttack: timeAttack, teams: teams}
+		h.createLobbyQueue <- c
+	case c.joinCode != "":
+		h.joinLobbyQueue <- c
+	default:
+		h.registerClientQueue <- c
+	}
+}
+
+// resumeClient hands a fresh connection to the client still holding the
+// token's place, the rest of the handshake happens in their state handler
+func (h *Hub) resumeClient(conn *websocket.Conn, token string) {
+	refuse := func() {
+		log.Println("Resume refused, no session for token")
+		if msg, err := (JoinFailedMessage{Reason: JoinFailedNotFound}).MarshalBinary(); err == nil {
+			conn.WriteMessage(websocket.BinaryMessage, msg)
+		}
+		conn.WriteMessage(websocket.CloseMessage, []byte{})
+		conn.Close()
+	}
+
+	c, ok := h.sessions.get(token)
+	if !ok {
+		refuse()
+		return
+	}
+
+	select {
+	case c.resume <- conn:
+		c.log("resume accepted")
+	case <-c.gone:
+		refuse()
+	}
 }
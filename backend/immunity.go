@@ -0,0 +1,92 @@
+package main
+
+import "time"
+
+// ---- Hits ----
+//
+// The lobby decides whether a powerup aimed at another racer lands. It
+// doesn't on teammates, on racers that have left, or on anyone hit in the
+// last Immunity seconds; the buyer gets their coins back when it doesn't.
+// Immunity starts once the target takes the hit, a shield or mirror stopping
+// it doesn't count. Until the target says which it was, the hit is pending and
+// nothing else lands on them either.
+
+func (l *Lobby) applyStatusEffect(msg ClientLobbyApplyStatusEffect) bool {
+	affected, ok := l.clients[msg.affectedClientId]
+	if !ok {
+		return false
+	}
+	r, ok := l.results[msg.affectedClientId]
+	if !ok || r.finished || r.disconnected {
+		return false
+	}
+	if l.sameTeam(msg.fromClientId, msg.affectedClientId) {
+		l.log("%d can't use powerups on teammate %d", msg.fromClientId, msg.affectedClientId)
+		return false
+	}
+
+	now := time.Now()
+	if now.Before(r.immuneUntil) {
+		l.log("%d is immune to %d's powerup for %s", msg.affectedClientId, msg.fromClientId, r.immuneUntil.Sub(now))
+		return false
+	}
+	if r.pendingHit {
+		l.log("%d is still taking a hit, %d's powerup doesn't land", msg.affectedClientId, msg.fromClientId)
+		return false
+	}
+	r.pendingHit = true
+
+	// the target may be waiting on the lobby itself
+	go affected.deliver(LobbyClientApplyStatusEffect{
+		powerupId:    msg.powerupId,
+		fromClientId: msg.fromClientId,
+	})
+	return true
+}
+
+// hit starts r's immunity, once an effect got past their shields
+func (l *Lobby) hit(r *playerResult, now time.Time) {
+	r.pendingHit = false
+	r.immuneUntil = now.Add(time.Duration(l.cfg.Powerups.Immunity) * time.Second)
+}
+
+// blocked lets r take hits again after a shield or mirror stopped one
+func (l *Lobby) blocked(r *playerResult) {
+	r.pendingHit = false
+}
+
+// statusMessage is r's effects, immunity and cooldowns as of now
+func (l *Lobby) statusMessage(r *playerResult, now time.Time) StatusChangedV2Message {
+	msg := StatusChangedV2Message{
+		PlayerID: r.id,
+		Immunity: millisUntil(r.immuneUntil, now),
+	}
+	for _, e := range r.effects {
+		state := EffectState{
+			PowerupID: byte(e.id),
+			Source:    e.from,
+			Intensity: byte(min(e.intensity, 0xff)),
+			Kind:      e.kind,
+		}
+		switch e.kind {
+		case DurationTimed:
+			state.Remaining = millisUntil(e.expires, now)
+		case DurationWords:
+			state.Remaining = uint32(max(e.wordsLeft, 0))
+		}
+		msg.Effects = append(msg.Effects, state)
+	}
+	for _, cd := range r.cooldowns {
+		if remaining := millisUntil(cd.until, now); remaining > 0 {
+			msg.Cooldowns = append(msg.Cooldowns, CooldownState{PowerupID: byte(cd.id), Remaining: remaining})
+		}
+	}
+	return msg
+}
+
+func millisUntil(t, now time.Time) uint32 {
+	if !now.Before(t) {
+		return 0
+	}
+	return uint32(t.Sub(now).Milliseconds())
+}
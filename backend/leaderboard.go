@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Leaderboards ranks players from every race the lobbies finish. All-time
+// totals are kept running; daily and weekly boards are worked out on request
+// from the races since the start of the week, which is all that's kept.
+// Windows follow the UTC calendar, weeks start on Monday.
+type Leaderboards struct {
+	mu sync.Mutex
+
+	allTime map[string]*playerTotals
+	recent  []recentEntry
+}
+
+type recentEntry struct {
+	at    time.Time
+	key   string
+	entry RaceEntry
+}
+
+// playerTotals only count races the player finished
+type playerTotals struct {
+	name  string
+	races int
+	wins  int
+
+	bestWpm     int
+	wpmSum      int
+	accuracySum float64
+}
+
+func (t *playerTotals) add(e RaceEntry) {
+	t.name = e.Name
+	t.races++
+	if e.Placement == 1 {
+		t.wins++
+	}
+	t.bestWpm = max(t.bestWpm, e.WPM)
+	t.wpmSum += e.WPM
+	t.accuracySum += float64(e.Accuracy)
+}
+
+type LeaderboardWindow string
+
+const (
+	WindowDaily   LeaderboardWindow = "daily"
+	WindowWeekly  LeaderboardWindow = "weekly"
+	WindowAllTime LeaderboardWindow = "all"
+)
+
+type LeaderboardMetric string
+
+const (
+	MetricBestWPM  LeaderboardMetric = "bestWpm"
+	MetricAvgWPM   LeaderboardMetric = "avgWpm"
+	MetricWins     LeaderboardMetric = "wins"
+	MetricAccuracy LeaderboardMetric = "accuracy"
+)
+
+var leaderboardMetrics = []LeaderboardMetric{MetricBestWPM, MetricAvgWPM, MetricWins, MetricAccuracy}
+
+// averages over a race or two aren't worth ranking
+const leaderboardMinRaces = 3
+
+func (m LeaderboardMetric) value(t *playerTotals) (float64, bool) {
+	switch m {
+	case MetricBestWPM:
+		return float64(t.bestWpm), true
+	case MetricAvgWPM:
+		return float64(t.wpmSum) / float64(t.races), t.races >= leaderboardMinRaces
+	case MetricWins:
+		return float64(t.wins), t.wins > 0
+	case MetricAccuracy:
+		return t.accuracySum / float64(t.races), t.races >= leaderboardMinRaces
+	default:
+		return 0, false
+	}
+}
+
+type LeaderboardEntry struct {
+	Rank  int     `json:"rank"`
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Races int     `json:"races"`
+}
+
+func NewLeaderboards(races []RaceRecord) *Leaderboards {
+	lb := &Leaderboards{
+		allTime: make(map[string]*playerTotals),
+	}
+	for _, race := range races {
+		lb.Record(race)
+	}
+	return lb
+}
+
+func (lb *Leaderboards) Record(race RaceRecord) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	weekStart := windowStart(WindowWeekly, time.Now())
+
+	for _, e := range race.Results {
+		key := playerKey(e.Name)
+		if key == "" || e.Bot || !e.finished() {
+			continue
+		}
+
+		t, ok := lb.allTime[key]
+		if !ok {
+			t = &playerTotals{}
+			lb.allTime[key] = t
+		}
+		t.add(e)
+
+		if !race.StartedAt.Before(weekStart) {
+			lb.recent = append(lb.recent, recentEntry{at: race.StartedAt, key: key, entry: e})
+		}
+	}
+
+	lb.prune(weekStart)
+}
+
+// prune drops races from before this week, callers hold mu
+func (lb *Leaderboards) prune(weekStart time.Time) {
+	lb.recent = slices.DeleteFunc(lb.recent, func(r recentEntry) bool {
+		return r.at.Before(weekStart)
+	})
+}
+
+func windowStart(window LeaderboardWindow, now time.Time) time.Time {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch window {
+	case WindowDaily:
+		return day
+	case WindowWeekly:
+		sinceMonday := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -sinceMonday)
+	default:
+		return time.Time{}
+	}
+}
+
+func (lb *Leaderboards) totals(window LeaderboardWindow) map[string]*playerTotals {
+	if window == WindowAllTime {
+		return lb.allTime
+	}
+
+	now := time.Now()
+	lb.prune(windowStart(WindowWeekly, now))
+	start := windowStart(window, now)
+
+	totals := make(map[string]*playerTotals)
+	for _, r := range lb.recent {
+		if r.at.Before(start) {
+			continue
+		}
+
+		t, ok := totals[r.key]
+		if !ok {
+			t = &playerTotals{}
+			totals[r.key] = t
+		}
+		t.add(r.entry)
+	}
+	return totals
+}
+
+// Rank returns the top limit players for metric over window, limit <= 0
+// means everyone
+func (lb *Leaderboards) Rank(metric LeaderboardMetric, window LeaderboardWindow, limit int) []LeaderboardEntry {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	return rank(lb.totals(window), metric, limit)
+}
+
+func (lb *Leaderboards) RankAll(window LeaderboardWindow, limit int) map[LeaderboardMetric][]LeaderboardEntry {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	totals := lb.totals(window)
+	boards := make(map[LeaderboardMetric][]LeaderboardEntry, len(leaderboardMetrics))
+	for _, m := range leaderboardMetrics {
+		boards[m] = rank(totals, m, limit)
+	}
+	return boards
+}
+
+func rank(totals map[string]*playerTotals, metric LeaderboardMetric, limit int) []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(totals))
+	for _, t := range totals {
+		if v, ok := metric.value(t); ok {
+			entries = append(entries, LeaderboardEntry{Name: t.name, Value: v, Races: t.races})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Value != b.Value {
+			return a.Value > b.Value
+		}
+		if a.Races != b.Races {
+			return a.Races > b.Races
+		}
+		return a.Name < b.Name
+	})
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	// ties share a rank
+	for i := range entries {
+		if i > 0 && entries[i].Value == entries[i-1].Value {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+
+	return entries
+}
+
+// ---- HTTP ----
+
+const defaultLeaderboardLimit = 10
+
+// leaderboardQuery reads ?window= and ?limit=, reporting a bad request itself
+func leaderboardQuery(w http.ResponseWriter, r *http.Request) (LeaderboardWindow, int, bool) {
+	window := LeaderboardWindow(r.URL.Query().Get("window"))
+	switch window {
+	case "":
+		window = WindowAllTime
+	case WindowDaily, WindowWeekly, WindowAllTime:
+	default:
+		http.Error(w, "window must be daily, weekly or all", http.StatusBadRequest)
+		return "", 0, false
+	}
+
+	limit := defaultLeaderboardLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a non-negative number", http.StatusBadRequest)
+			return "", 0, false
+		}
+		limit = n
+	}
+
+	return window, limit, true
+}
+
+func (lb *Leaderboards) ServeAll(w http.ResponseWriter, r *http.Request) {
+	window, limit, ok := leaderboardQuery(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lb.RankAll(window, limit))
+}
+
+func (lb *Leaderboards) ServeMetric(w http.ResponseWriter, r *http.Request) {
+	metric := LeaderboardMetric(r.PathValue("metric"))
+	if !slices.Contains(leaderboardMetrics, metric) {
+		http.NotFound(w, r)
+		return
+	}
+
+	window, limit, ok := leaderboardQuery(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lb.Rank(metric, window, limit))
+}
@@ -9,12 +9,13 @@ import (
 
 type ClientId = byte
 
-const ClientsPerLobby = 4
-const LobbyWait uint16 = 25
-const DisplayedPowerupCount = 4
-const AllowedPowerupCount = 2
+// public lobbies stop taking new players this many seconds before the start
+const lobbyCloseLead uint16 = 10
 
-const WordCount = 50
+// the countdown is broadcast every raceCountdownInterval seconds, then every
+// second once raceCountdownFinal seconds remain
+const raceCountdownInterval = 10
+const raceCountdownFinal = 10
 
 type Lobby struct {
 	id         int
@@ -25,16 +26,41 @@ type Lobby struct {
 
 	clients map[ClientId]*Client
 
+	watch      chan *Client
+	unwatch    chan *Client
+	spectators map[*Client]struct{}
+
+	// open is the lobby's own, the hub watches sealed, closed once it stops
+	// taking matchmade players
 	open   bool
+	sealed chan struct{}
 	done   chan struct{}
 	closed bool
 
 	hub *Hub
-
-	words []string
+	cfg *Config
+
+	// private lobbies are only joinable by code
+	private bool
+	code    string
+
+	source WordSource
+	words  []string
+	// seconds, 0 unless this is a time attack race
+	timeAttack uint16
+	// how many teams, 0 unless this is a team race
+	teams byte
+	// the tournament heat this lobby races, if any
+	heat *Heat
+
+	replay *replayRecorder
+
+	startAt   time.Time
+	raceStart time.Time
+	results   map[ClientId]*playerResult
 }
 
-func newLobby(id int, hub *Hub) *Lobby {
+func newLobby(id int, hub *Hub, source WordSource) *Lobby {
 	l := &Lobby{
 		id:         id,
 		register:   make(chan *Client),
@@ -44,15 +70,45 @@ func newLobby(id int, hub *Hub) *Lobby {
 
 		clients: make(map[ClientId]*Client),
 
-		open:   false,
+		watch:      make(chan *Client),
+		unwatch:    make(chan *Client),
+		spectators: make(map[*Client]struct{}),
+
+		open:   true,
+		sealed: make(chan struct{}),
 		done:   make(chan struct{}),
 		closed: false,
 
 		hub: hub,
+		cfg: hub.cfg,
+
+		source: source,
 
-		words: RandomWords(wordsEnglish, WordCount),
+		replay: newReplayRecorder(id),
 	}
 
+	if p, ok := source.(Passage); ok {
+		l.words = p.Passage()
+	} else {
+		l.words = source.Words(hub.cfg.WordCount)
+	}
+
+	return l
+}
+
+// lobbyOptions are what a private lobby's creator picks for its race
+type lobbyOptions struct {
+	source     WordSource
+	timeAttack uint16
+	teams      byte
+}
+
+func newPrivateLobby(id int, hub *Hub, code string, opts lobbyOptions) *Lobby {
+	l := newLobby(id, hub, opts.source)
+	l.private = true
+	l.code = code
+	l.timeAttack = opts.timeAttack
+	l.teams = opts.teams
 	return l
 }
 
@@ -61,44 +117,79 @@ func (l *Lobby) clientCount() int {
 }
 
 func (l *Lobby) run() {
-	l.log("Running")
-
-	l.open = true
+	l.log("Running with words from %s", l.source.Name())
 
 	var clientId byte = 0
 
-	startGameTimer := time.NewTimer(time.Duration(LobbyWait) * time.Second)
+	wait := l.cfg.LobbyWait
+	if l.private {
+		wait = l.cfg.PrivateLobbyWait
+	}
+
+	startGameTimer := time.NewTimer(time.Duration(wait) * time.Second)
 	timerStart := time.Now()
+	l.startAt = timerStart.Add(time.Duration(wait) * time.Second)
 
-	openLobbyTimer := time.NewTimer(time.Duration(LobbyWait-10) * time.Second)
+	openLobbyTimer := time.NewTimer(time.Duration(wait-lobbyCloseLead) * time.Second)
 
 startGameLoop:
 	for {
 		select {
 		case client := <-l.register:
-			if l.clientCount() == ClientsPerLobby {
-				l.hub.registerClientQueue <- client
+			if l.clientCount() == l.cfg.ClientsPerLobby {
+				l.requeue(client, JoinFailedFull)
+				continue
+			}
+			if l.heat != nil && (!l.heat.admits(client.name) || l.hasPlayer(client.name)) {
+				l.requeue(client, JoinFailedNotSeeded)
 				continue
 			}
 			client.id = clientId
 			clientId++
 
-			remainingTime := LobbyWait - (uint16(time.Since(timerStart) / time.Second))
+			remainingTime := wait - (uint16(time.Since(timerStart) / time.Second))
 
 			l.registerClient(client, remainingTime)
 
+			// heats start as soon as everyone seeded is there
+			if l.heat != nil && l.clientCount() == len(l.heat.Players) {
+				break startGameLoop
+			}
+
 		case <-startGameTimer.C:
+			for _, b := range l.backfill() {
+				b.id = clientId
+				clientId++
+				l.registerClient(b, 0)
+			}
 			break startGameLoop
 
 		case <-openLobbyTimer.C:
-			l.open = false
+			// private lobbies stay joinable by code until the race starts
+			if !l.private {
+				l.seal()
+			}
 
 		case msg := <-l.lobbyRead:
-			_, ok := msg.(ClientLobbySkipWait)
-			if ok {
-				break startGameLoop
+			l.replay.event(msg)
+
+			switch msg := msg.(type) {
+			case ClientLobbySkipWait:
+				// a heat waits for the players who haven't shown up yet
+				if l.heat == nil {
+					break startGameLoop
+				}
+
+			case ClientLobbyResumed:
+				l.resendState(msg)
 			}
 
+		case s := <-l.watch:
+			l.addSpectator(s)
+
+		case s := <-l.unwatch:
+			delete(l.spectators, s)
+
 		case <-l.done:
 			l.close()
 			return
@@ -106,53 +197,153 @@ startGameLoop:
 
 	}
 
-	l.open = false
+	l.seal()
 
 	activePlayers := l.clientCount()
+	finishedPlayers := 0
+	l.newResults()
+	l.assignTeams()
 
 	l.log("wait over, starting game")
 
+	l.raceStart = time.Now()
 	l.broadcast(RaceStartedMessage{})
+	if l.teams > 0 {
+		l.broadcast(l.teamAssignment())
+	}
+	raceTimeLimit := l.raceTimeLimit()
+	l.broadcast(RaceCountdownMessage{TimeRemaining: raceTimeLimit})
 
-	// TODO: mayhaps add game timer
+	raceTimer := time.NewTimer(time.Duration(raceTimeLimit) * time.Second)
+	defer raceTimer.Stop()
+	countdownTicker := time.NewTicker(time.Second)
+	defer countdownTicker.Stop()
 
 	for {
 		select {
 
+		case client := <-l.register:
+			l.requeue(client, JoinFailedStarted)
+
 		case msg := <-l.lobbyRead:
+			l.replay.event(msg)
+
 			switch msg := msg.(type) {
 			case ClientLobbySkipWait:
 
 			case ClientLobbyProgressUpdate:
-				l.broadcast(ProgressUpdateMessage{
+				r := l.results[msg.clientId]
+				r.progress = msg.progress
+				r.wpm = uint32(msg.wpm)
+				r.rawWpm = uint32(msg.rawWpm)
+				r.accuracy = msg.accuracy
+				r.wordsTyped = msg.words
+
+				l.broadcast(ProgressUpdateV2Message{
 					PlayerID: msg.clientId,
 					Progress: msg.progress,
 					WPM:      uint32(msg.wpm),
+					RawWPM:   uint32(msg.rawWpm),
+					Accuracy: msg.accuracy,
 				})
+				if l.teams > 0 {
+					l.broadcast(l.teamProgress(r.team))
+				}
 
 			case ClientLobbyFinished:
-				l.broadcast(PlayerFinishedMessage{
+				finishedPlayers++
+
+				r := l.results[msg.clientId]
+				r.finished = true
+				r.placement = byte(finishedPlayers)
+				r.progress = 1
+				r.wpm = uint32(msg.wpm)
+				r.accuracy = msg.accuracy
+				r.timeTaken = time.Since(l.raceStart)
+
+				l.broadcast(PlayerFinishedV2Message{
 					PlayerID:  msg.clientId,
-					Placement: byte(len(l.clients) - activePlayers + 1),
+					Placement: r.placement,
+					WPM:       r.wpm,
+					Accuracy:  r.accuracy,
 				})
 				activePlayers--
 
+			case ClientLobbyPowerupUsed:
+				r := l.results[msg.clientId]
+				r.powerupsUsed = append(r.powerupsUsed, msg.powerupId)
+
+			case ClientLobbyEffectReceived:
+				r := l.results[msg.clientId]
+				r.effectsReceived = append(r.effectsReceived, msg.powerupId)
+				l.hit(r, time.Now())
+
+			case ClientLobbyEffectBlocked:
+				l.blocked(l.results[msg.clientId])
+
+			case ClientLobbyResumed:
+				l.resendState(msg)
+
 			case ClientLobbyApplyStatusEffect:
-				l.clients[msg.affectedClientId].lobbyMsgWrite <- LobbyClientApplyStatusEffect{
-					powerupId:    msg.powerupId,
-					fromClientId: msg.fromClientId,
+				landed := l.applyStatusEffect(msg)
+				if msg.reflected {
+					continue
+				}
+				if from, ok := l.clients[msg.fromClientId]; ok {
+					go from.deliver(LobbyClientPurchaseLanded{
+						powerupId: msg.powerupId,
+						landed:    landed,
+					})
 				}
 
 			case ClientLobbyStatusChanged:
-				l.broadcast(StatusChangedMessage{
-					PlayerID:        msg.clientId,
-					StatusEffectIDs: msg.powerupIds,
-				})
+				r := l.results[msg.clientId]
+				r.effects = msg.effects
+				r.cooldowns = msg.cooldowns
+
+				l.broadcast(l.statusMessage(r, time.Now()))
 
 			}
 
-		case <-l.unregister:
-			activePlayers--
+		case client := <-l.unregister:
+			l.replay.left(client.id)
+
+			// finishers already left the active count
+			r := l.results[client.id]
+			if !r.finished {
+				r.disconnected = true
+				r.timeTaken = time.Since(l.raceStart)
+				activePlayers--
+			}
+
+		case <-countdownTicker.C:
+			remaining := l.raceTimeRemaining()
+			if remaining == 0 {
+				continue
+			}
+			if remaining%raceCountdownInterval == 0 || remaining <= raceCountdownFinal {
+				l.broadcast(RaceCountdownMessage{TimeRemaining: remaining})
+			}
+			if l.timeAttack > 0 {
+				l.tickTimeAttack()
+			}
+
+		case <-raceTimer.C:
+			l.log("time limit reached, %d players did not finish", activePlayers)
+			for _, r := range l.results {
+				if !r.finished && !r.disconnected {
+					r.timeTaken = time.Duration(raceTimeLimit) * time.Second
+					// the clock is the finish line in time attack
+					r.finished = l.timeAttack > 0
+				}
+			}
+			activePlayers = 0
+
+		case s := <-l.watch:
+			l.addSpectator(s)
+
+		case s := <-l.unwatch:
+			delete(l.spectators, s)
 
 		case <-l.done:
 			l.close()
@@ -161,7 +352,23 @@ startGameLoop:
 		}
 
 		if activePlayers == 0 {
+			l.broadcast(l.raceResults())
+			if l.teams > 0 {
+				l.broadcast(l.teamResults(l.standings()))
+			}
+			race := l.raceRecord()
+			if err := l.hub.store.SaveRace(race); err != nil {
+				l.log("saving race: %s", err)
+			}
+			l.hub.leaderboards.Record(race)
+			l.hub.ratings.Record(race)
+			if l.heat != nil {
+				l.hub.tournaments.heatFinished(l.heat, race)
+			}
+			// the race is over, nothing queued behind this may run it again
 			close(l.done)
+			l.close()
+			return
 		}
 	}
 }
@@ -176,33 +383,222 @@ func (l *Lobby) registerClient(c *Client, timeRemaining uint16) {
 
 	c.unregister = l.unregister
 	c.lobbyRead = l.lobbyRead
+	c.lobbyDone = l.done
+	c.cfg = l.cfg
 	c.words = append([]string{}, l.words...)
+	c.plain = plainWords(c.words)
+	c.wordSource = l.source
+	c.timeAttack = l.timeAttack
+	c.offeredPowerups = rand.Perm(int(PowerupCount))[:l.cfg.DisplayedPowerupCount]
 
-	go c.readPump()
+	c.resumeToken = newResumeToken()
+	l.hub.sessions.add(c)
 
 	l.clients[c.id] = c
 
-	c.lobbyWrite <- LobbyGreetingMessage{
+	c.send(LobbyGreetingMessage{
 		PlayerID:      c.id,
 		TimeRemaining: timeRemaining,
 		Players:       l.players(),
-		Words:         c.words,
-		Powerups:      rand.Perm(int(PowerupCount))[:DisplayedPowerupCount],
+		Words:         l.words,
+		Powerups:      c.offeredPowerups,
+		ResumeToken:   c.resumeToken,
+		LobbyID:       uint32(l.id),
+	})
+
+	if l.private {
+		c.send(LobbyCodeMessage{Code: l.code})
+	}
+	l.sendQuote(c)
+	c.send(powerupCostsMessage(l.cfg.Powerups))
+
+	go c.stateHandler()
+	if c.bot != nil {
+		go c.bot.run()
+	} else {
+		go c.readPump(c.conn)
+	}
+
+	if l.clientCount() == l.cfg.ClientsPerLobby {
+		l.seal()
 	}
+}
 
-	if l.clientCount() == ClientsPerLobby {
+// seal stops matchmaking picking this lobby
+func (l *Lobby) seal() {
+	if l.open {
 		l.open = false
+		close(l.sealed)
+	}
+}
+
+// accepting is for the hub, whether the lobby still takes matchmade players
+func (l *Lobby) accepting() bool {
+	select {
+	case <-l.sealed:
+		return false
+	default:
+		return true
+	}
+}
+
+// requeue hands a client this lobby can't take back to matchmaking, or turns
+// them away if they asked for this lobby by code
+func (l *Lobby) requeue(c *Client, reason JoinFailedReason) {
+	if l.private {
+		c.reject(reason)
+		return
 	}
+	// the hub may be blocked handing this lobby another player
+	go func() { l.hub.registerClientQueue <- c }()
 }
 
 func (l *Lobby) broadcast(msg ServerMessage) {
+	l.replay.broadcast(msg)
+
 	for _, c := range l.clients {
-		if !c.closed {
-			c.lobbyWrite <- msg
+		c.send(msg)
+	}
+	for s := range l.spectators {
+		s.send(msg)
+	}
+}
+
+// resendState replays the lobby to a client that just resumed, followed by
+// their own state as the client handed it over
+func (l *Lobby) resendState(msg ClientLobbyResumed) {
+	c, ok := l.clients[msg.clientId]
+	if !ok {
+		return
+	}
+
+	var timeRemaining uint16
+	if l.raceStart.IsZero() {
+		timeRemaining = uint16(max(time.Until(l.startAt)/time.Second, 0))
+	}
+
+	c.send(LobbyGreetingMessage{
+		PlayerID:      c.id,
+		TimeRemaining: timeRemaining,
+		Players:       l.players(),
+		Words:         msg.words,
+		Powerups:      c.offeredPowerups,
+		ResumeToken:   c.resumeToken,
+		LobbyID:       uint32(l.id),
+	})
+
+	if l.private {
+		c.send(LobbyCodeMessage{Code: l.code})
+	}
+	l.sendQuote(c)
+
+	if !l.raceStart.IsZero() {
+		c.send(RaceStartedMessage{})
+		l.sendRaceState(c)
+	}
+
+	c.send(ResumeStateMessage{
+		Index:    uint32(msg.idx),
+		Powerups: msg.powerups,
+	})
+	c.send(powerupCostsMessage(l.cfg.Powerups))
+	c.send(msg.purse.message())
+}
+
+// sendRaceState catches a late arrival up on everyone's progress
+func (l *Lobby) sendRaceState(c *Client) {
+	c.send(RaceCountdownMessage{TimeRemaining: l.raceTimeRemaining()})
+
+	if l.teams > 0 {
+		c.send(l.teamAssignment())
+		for t := range l.teams {
+			c.send(l.teamProgress(t))
+		}
+	}
+
+	for _, r := range l.results {
+		c.send(ProgressUpdateV2Message{
+			PlayerID: r.id,
+			Progress: r.progress,
+			WPM:      r.wpm,
+			RawWPM:   r.rawWpm,
+			Accuracy: r.accuracy,
+		})
+
+		if r.finished {
+			c.send(PlayerFinishedV2Message{
+				PlayerID:  r.id,
+				Placement: r.placement,
+				WPM:       r.wpm,
+				Accuracy:  r.accuracy,
+			})
+		}
+
+		if msg := l.statusMessage(r, time.Now()); len(msg.Effects) > 0 || len(msg.Cooldowns) > 0 || msg.Immunity > 0 {
+			c.send(msg)
 		}
 	}
 }
 
+func (l *Lobby) sendQuote(c *Client) {
+	if q, ok := l.source.(*quoteSource); ok {
+		c.send(q.message())
+	}
+}
+
+func (l *Lobby) addSpectator(c *Client) {
+	c.lobbyDone = l.done
+	c.unwatch = l.unwatch
+	l.spectators[c] = struct{}{}
+
+	l.log("spectator joined")
+
+	var timeRemaining uint16
+	if l.raceStart.IsZero() {
+		timeRemaining = uint16(max(time.Until(l.startAt)/time.Second, 0))
+	}
+
+	c.send(SpectatorGreetingMessage{
+		LobbyID:       uint32(l.id),
+		TimeRemaining: timeRemaining,
+		RaceStarted:   !l.raceStart.IsZero(),
+		Players:       l.players(),
+	})
+
+	l.sendQuote(c)
+
+	if !l.raceStart.IsZero() {
+		l.sendRaceState(c)
+	}
+
+	go c.spectate()
+}
+
+func (l *Lobby) raceTimeLimit() uint16 {
+	if l.timeAttack > 0 {
+		return l.timeAttack
+	}
+	return l.cfg.RaceTimeLimit
+}
+
+func (l *Lobby) raceTimeRemaining() uint16 {
+	limit := l.raceTimeLimit()
+	elapsed := uint16(time.Since(l.raceStart) / time.Second)
+	if elapsed >= limit {
+		return 0
+	}
+	return limit - elapsed
+}
+
+func (l *Lobby) hasPlayer(name string) bool {
+	for _, c := range l.clients {
+		if playerKey(c.name) == playerKey(name) {
+			return true
+		}
+	}
+	return false
+}
+
 func (l *Lobby) players() []Player {
 	ps := make([]Player, 0, 4)
 	for _, c := range l.clients {
@@ -214,7 +610,18 @@ func (l *Lobby) players() []Player {
 func (l *Lobby) close() {
 	l.log("closing")
 	for _, client := range l.clients {
-		client.conn.Close()
+		client.disconnect()
+	}
+	for s := range l.spectators {
+		s.disconnect()
 	}
 	l.closed = true
+
+	if l.cfg.ReplayDir != "" {
+		if err := l.replay.save(l.cfg.ReplayDir); err != nil {
+			l.log("saving replay: %s", err)
+		}
+	}
+
+	l.hub.closedLobbies <- l
 }
@@ -1,8 +1,31 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
 )
 
 func main() {
-	Serve()
+	configPath := flag.String("config", os.Getenv("OVERTYPED_CONFIG"), "path to a JSON config file")
+	dumpConfig := flag.Bool("dump-config", false, "print the effective config and exit")
+	flag.Parse()
+
+	if *dumpConfig {
+		cfg, err := LoadConfig(*configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		out, err := cfg.Dump()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Println(string(out))
+		return
+	}
+
+	Serve(*configPath)
 }
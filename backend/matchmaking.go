@@ -0,0 +1,157 @@
+package main
+
+import (
+	"log"
+	"math"
+	"slices"
+	"time"
+)
+
+// how often the hub looks for matches besides whenever a client queues
+const matchmakingInterval = time.Second
+
+// Public players wait in the hub's queue until there's a lobby near their
+// rating. How near starts at the configured tolerance and widens the longer
+// they wait, until after MatchmakingMaxWait seconds anyone will do.
+type queuedClient struct {
+	client *Client
+	rating float64
+	since  time.Time
+}
+
+// formingLobby is a matchmade lobby still waiting for its race, with the
+// ratings the hub put in it
+type formingLobby struct {
+	lobby     *Lobby
+	ratingSum float64
+	count     int
+}
+
+func (f *formingLobby) rating() float64 {
+	return f.ratingSum / float64(f.count)
+}
+
+func (h *Hub) joinFormingLobby(f *formingLobby, q queuedClient) {
+	select {
+	case f.lobby.register <- q.client:
+		f.ratingSum += q.rating
+		f.count++
+	case <-f.lobby.done:
+		// never pick this lobby again
+		f.count = h.cfg.ClientsPerLobby
+		h.queue = append(h.queue, q)
+	}
+}
+
+// enqueue queues c, as of when they first queued so a requeued client keeps
+// the tolerance they've earned
+func (h *Hub) enqueue(c *Client) {
+	if c.queuedAt.IsZero() {
+		c.queuedAt = time.Now()
+	}
+	h.queue = append(h.queue, queuedClient{
+		client: c,
+		rating: h.ratings.Get(c.name),
+		since:  c.queuedAt,
+	})
+}
+
+func (h *Hub) tolerance(q queuedClient, now time.Time) float64 {
+	waited := now.Sub(q.since)
+	if waited >= time.Duration(h.cfg.MatchmakingMaxWait)*time.Second {
+		return math.Inf(1)
+	}
+	return float64(h.cfg.MatchmakingTolerance) + float64(h.cfg.MatchmakingWiden)*waited.Seconds()
+}
+
+// matchmake places whoever it can, oldest first: into a forming lobby close
+// enough to their rating, or else a new lobby with the nearest queued players
+// once there are enough of them or they've waited long enough
+func (h *Hub) matchmake() {
+	now := time.Now()
+
+	h.forming = slices.DeleteFunc(h.forming, func(f *formingLobby) bool {
+		return !f.lobby.accepting() || f.count >= h.cfg.ClientsPerLobby
+	})
+
+	for i := 0; i < len(h.queue); {
+		q := h.queue[i]
+		tol := h.tolerance(q, now)
+
+		if f := h.formingLobbyFor(q.rating, tol); f != nil {
+			q.client.log("matched into lobby %d (rating %.0f, lobby %.0f)", f.lobby.id, q.rating, f.rating())
+			h.queue = slices.Delete(h.queue, i, i+1)
+			h.joinFormingLobby(f, q)
+			continue
+		}
+
+		group := h.nearest(q, tol)
+		if len(group) < h.cfg.ClientsPerLobby && tol != math.Inf(1) {
+			i++
+			continue
+		}
+
+		f := &formingLobby{lobby: newLobby(lId, h, h.words.defaultSource())}
+		go f.lobby.run()
+		lId++
+
+		h.active[f.lobby.id] = f.lobby
+		h.forming = append(h.forming, f)
+		log.Printf("Matchmade lobby %d for %d players around %.0f", f.lobby.id, len(group), q.rating)
+
+		h.queue = slices.DeleteFunc(h.queue, func(m queuedClient) bool {
+			return slices.ContainsFunc(group, func(g queuedClient) bool { return g.client == m.client })
+		})
+		for _, m := range group {
+			h.joinFormingLobby(f, m)
+		}
+
+		// the queue shifted, start again from the oldest
+		i = 0
+	}
+}
+
+func (h *Hub) formingLobbyFor(rating, tol float64) *formingLobby {
+	var best *formingLobby
+	for _, f := range h.forming {
+		if !f.lobby.accepting() || f.count >= h.cfg.ClientsPerLobby {
+			continue
+		}
+
+		diff := math.Abs(f.rating() - rating)
+		if diff <= tol && (best == nil || diff < math.Abs(best.rating()-rating)) {
+			best = f
+		}
+	}
+	return best
+}
+
+// nearest is q and the queued players closest to their rating within tol, as
+// many as fit in a lobby
+func (h *Hub) nearest(q queuedClient, tol float64) []queuedClient {
+	var candidates []queuedClient
+	for _, m := range h.queue {
+		if math.Abs(m.rating-q.rating) <= tol {
+			candidates = append(candidates, m)
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(a, b queuedClient) int {
+		if a.client == q.client {
+			return -1
+		}
+		if b.client == q.client {
+			return 1
+		}
+		da, db := math.Abs(a.rating-q.rating), math.Abs(b.rating-q.rating)
+		switch {
+		case da < db:
+			return -1
+		case da > db:
+			return 1
+		}
+		return 0
+	})
+
+	return candidates[:min(len(candidates), h.cfg.ClientsPerLobby)]
+}
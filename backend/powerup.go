@@ -1,5 +1,12 @@
 package main
 
+import (
+	"fmt"
+	"time"
+)
+
+// PowerupId is a powerup on the wire. Ids are fixed once a client knows
+// them, new powerups go before PowerupCount.
 type PowerupId byte
 
 const (
@@ -10,18 +17,159 @@ const (
 	PowerupTireBoot
 	PowerupScrambler
 	PowerupRearViewMirror
+	PowerupReverseGear
+	PowerupPotholes
+	PowerupMirage
+	PowerupBumpyRoad
+	PowerupTailgate
+	PowerupShield
+	PowerupNitro
+	PowerupCleanse
+	PowerupAutocorrect
 	PowerupCount
 )
 
+// ---- Powerups ----
+//
+// A Powerup describes an effect: how long it lasts and what it does to the
+// racer it lands on. What it does comes from the optional interfaces below,
+// which the state handler checks for when an effect is applied and when it
+// runs out. Each powerup registers itself in init, see effects.go.
+
+type Powerup interface {
+	Id() PowerupId
+	// Name identifies the powerup in config and race history
+	Name() string
+	// DefaultCost is the price in coins unless the config sets one
+	DefaultCost() int
+	Duration(pc PowerupConfig) EffectDuration
+}
+
+// a SelfPowerup lands on whoever buys it instead of an opponent
+type SelfPowerup interface {
+	TargetsSelf()
+}
+
+// a WordTransform rewrites the target's words from start, which is a few
+// words ahead of where they're typing
+type WordTransform interface {
+	Transform(t *effectTarget, words []string, start int) []string
+}
+
+// an ApplyHook runs when the effect lands, after any word transform
+type ApplyHook interface {
+	Apply(t *effectTarget)
+}
+
+// an ExpireHook runs when the effect runs out
+type ExpireHook interface {
+	Expire(t *effectTarget)
+}
+
+// a Reflector sends the next effect aimed at its holder back at the sender,
+// and is used up doing so
+type Reflector interface {
+	Reflects(incoming Powerup) bool
+}
+
+// an Absorber stops the next effect aimed at its holder, and is used up
+// doing so
+type Absorber interface {
+	Absorbs(incoming Powerup) bool
+}
+
+// a ProgressBooster counts every word typed while it's on for more progress
+// than its length, without touching wpm
+type ProgressBooster interface {
+	ProgressMultiplier(pc PowerupConfig) float64
+}
+
+// a Stacker decides what another hit does while the effect is still on,
+// the default is to start it over
+type Stacker interface {
+	Stacking() StackRule
+}
+
+type StackMode byte
+
 const (
-	fogDuration            = 10
-	tireBootDuration       = 10
-	rearViewMirrorDuration = 10
+	// starts the effect over
+	StackRefresh StackMode = iota
+	// adds to what's left, half as much each time
+	StackExtend
+	// makes the effect stronger up to MaxIntensity, and starts it over
+	StackIntensity
+)
+
+type StackRule struct {
+	Mode         StackMode
+	MaxIntensity int
+}
 
-	spikeStripWordsAdded = 5
-	wordsScrambled       = 10
-	wordsIced            = 10
-	wordsStickShifted    = 10
+func stacking(p Powerup) StackRule {
+	if s, ok := p.(Stacker); ok {
+		return s.Stacking()
+	}
+	return StackRule{Mode: StackRefresh}
+}
 
-	powerupOffset = 3
+type DurationKind byte
+
+const (
+	// runs out after a while
+	DurationTimed DurationKind = iota
+	// runs out once the target has typed some words
+	DurationWords
+	// does what it does when it lands and is gone
+	DurationInstant
 )
+
+type EffectDuration struct {
+	Kind  DurationKind
+	Time  time.Duration
+	Words int
+}
+
+func timedEffect(seconds int) EffectDuration {
+	return EffectDuration{Kind: DurationTimed, Time: time.Duration(seconds) * time.Second}
+}
+
+func wordsEffect(words int) EffectDuration {
+	return EffectDuration{Kind: DurationWords, Words: words}
+}
+
+func instantEffect() EffectDuration {
+	return EffectDuration{Kind: DurationInstant}
+}
+
+var powerupRegistry [PowerupCount]Powerup
+
+func registerPowerup(p Powerup) {
+	id := p.Id()
+	if id >= PowerupCount {
+		panic(fmt.Sprintf("powerup %s: id %d is past PowerupCount", p.Name(), id))
+	}
+	if powerupRegistry[id] != nil {
+		panic(fmt.Sprintf("powerup %s: id %d is taken by %s", p.Name(), id, powerupRegistry[id].Name()))
+	}
+	powerupRegistry[id] = p
+}
+
+func lookupPowerup(id byte) (Powerup, bool) {
+	if id >= byte(PowerupCount) || powerupRegistry[id] == nil {
+		return nil, false
+	}
+	return powerupRegistry[id], true
+}
+
+func targetsSelf(p Powerup) bool {
+	_, ok := p.(SelfPowerup)
+	return ok
+}
+
+func (p PowerupId) String() string {
+	if powerup, ok := lookupPowerup(byte(p)); ok {
+		return powerup.Name()
+	}
+	return "unknown"
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// ---- Quotes ----
+//
+// Quote races type a real passage start to finish, punctuation and
+// capitalization included, instead of random words. The corpus is embedded
+// from quotes.json; a quote's length category comes from its length.
+
+//go:embed quotes.json
+var quotesJSON []byte
+
+type Quote struct {
+	Text   string `json:"text"`
+	Author string `json:"author"`
+	Source string `json:"source"`
+}
+
+type QuoteLength byte
+
+const (
+	QuoteShort QuoteLength = iota
+	QuoteMedium
+	QuoteLong
+	quoteLengthCount
+)
+
+const quoteSourceName = "quote"
+
+// upper bounds in characters, anything longer is long
+const quoteShortMax = 100
+const quoteMediumMax = 250
+
+var quoteLengthNames = [quoteLengthCount]string{
+	QuoteShort:  "short",
+	QuoteMedium: "medium",
+	QuoteLong:   "long",
+}
+
+func (l QuoteLength) String() string {
+	if l >= quoteLengthCount {
+		return "unknown"
+	}
+	return quoteLengthNames[l]
+}
+
+func (q Quote) Length() QuoteLength {
+	switch n := len(q.Text); {
+	case n < quoteShortMax:
+		return QuoteShort
+	case n < quoteMediumMax:
+		return QuoteMedium
+	default:
+		return QuoteLong
+	}
+}
+
+type quoteCorpus struct {
+	byLength [quoteLengthCount][]Quote
+}
+
+func loadQuotes() (*quoteCorpus, error) {
+	var quotes []Quote
+	if err := json.Unmarshal(quotesJSON, &quotes); err != nil {
+		return nil, fmt.Errorf("quotes: %w", err)
+	}
+
+	c := &quoteCorpus{}
+	for i, q := range quotes {
+		if len(strings.Fields(q.Text)) == 0 {
+			return nil, fmt.Errorf("quotes: quote %d is empty", i)
+		}
+		if len(q.Author) > 255 || len(q.Source) > 255 {
+			return nil, fmt.Errorf("quotes: quote %d attribution is longer than 255 bytes", i)
+		}
+
+		l := q.Length()
+		c.byLength[l] = append(c.byLength[l], q)
+	}
+
+	for l, qs := range c.byLength {
+		if len(qs) == 0 {
+			return nil, fmt.Errorf("quotes: no %s quotes", QuoteLength(l))
+		}
+	}
+
+	return c, nil
+}
+
+// pick chooses a quote of the given length, or of any length if length is
+// quoteLengthCount
+func (c *quoteCorpus) pick(length QuoteLength) Quote {
+	if length >= quoteLengthCount {
+		length = QuoteLength(rand.Intn(int(quoteLengthCount)))
+	}
+	qs := c.byLength[length]
+	return qs[rand.Intn(len(qs))]
+}
+
+// resolve takes the part of a quote source after quote:, empty for any length
+func (c *quoteCorpus) resolve(length string) (WordSource, error) {
+	if length == "" {
+		return newQuoteSource(c.pick(quoteLengthCount)), nil
+	}
+
+	for l, name := range quoteLengthNames {
+		if strings.EqualFold(length, name) {
+			return newQuoteSource(c.pick(QuoteLength(l))), nil
+		}
+	}
+	return nil, fmt.Errorf("no %q quote length", length)
+}
+
+// quoteSource is one quote, picked when the lobby is made
+type quoteSource struct {
+	quote Quote
+	words []string
+}
+
+func newQuoteSource(q Quote) *quoteSource {
+	return &quoteSource{quote: q, words: strings.Fields(q.Text)}
+}
+
+func (q *quoteSource) Name() string {
+	return quoteSourceName + ":" + q.quote.Length().String()
+}
+
+// Words draws from the passage, for powerups that add words mid-race
+func (q *quoteSource) Words(n int) []string {
+	return RandomWords(q.words, n)
+}
+
+func (q *quoteSource) Passage() []string {
+	return q.words
+}
+
+func (q *quoteSource) message() QuoteMessage {
+	return QuoteMessage{
+		Author: q.quote.Author,
+		Source: q.quote.Source,
+		Length: q.quote.Length(),
+	}
+}
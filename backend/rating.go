@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"sync"
+)
+
+// Ratings are Elo ratings worked out from race placements. A race of n
+// players counts as every pair of them playing a game, won by whoever placed
+// higher, with each player's K shared out over their n-1 games so a bigger
+// lobby doesn't move ratings further. Nothing is stored, the ratings are
+// rebuilt from race history on startup.
+type Ratings struct {
+	mu sync.Mutex
+
+	players map[string]*rating
+}
+
+type rating struct {
+	value float64
+	races int
+}
+
+const initialRating = 1500
+
+// new players move quickly until they've raced enough to place them
+const (
+	provisionalRaces = 10
+	provisionalK     = 48
+	establishedK     = 24
+)
+
+func NewRatings(races []RaceRecord) *Ratings {
+	r := &Ratings{
+		players: make(map[string]*rating),
+	}
+	for _, race := range races {
+		r.Record(race)
+	}
+	return r
+}
+
+// Get is the player's rating, or initialRating if they haven't raced
+func (r *Ratings) Get(name string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p, ok := r.players[playerKey(name)]; ok {
+		return p.value
+	}
+	return initialRating
+}
+
+func (r *Ratings) Record(race RaceRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type racer struct {
+		*rating
+		placement int
+	}
+
+	racers := make([]racer, 0, len(race.Results))
+	for _, e := range race.Results {
+		key := playerKey(e.Name)
+		// bots play at whatever speed they're given, beating one means nothing
+		if key == "" || e.Bot {
+			continue
+		}
+
+		p, ok := r.players[key]
+		if !ok {
+			p = &rating{value: initialRating}
+			r.players[key] = p
+		}
+		racers = append(racers, racer{p, e.Placement})
+	}
+
+	if len(racers) < 2 {
+		return
+	}
+
+	// work every change out from the ratings before the race
+	deltas := make([]float64, len(racers))
+	for i, a := range racers {
+		k := establishedK
+		if a.races < provisionalRaces {
+			k = provisionalK
+		}
+
+		var score, expected float64
+		for j, b := range racers {
+			if i == j {
+				continue
+			}
+
+			switch {
+			case a.placement < b.placement:
+				score++
+			case a.placement == b.placement:
+				score += 0.5
+			}
+			expected += 1 / (1 + math.Pow(10, (b.value-a.value)/400))
+		}
+
+		deltas[i] = float64(k) * (score - expected) / float64(len(racers)-1)
+	}
+
+	for i, a := range racers {
+		a.value += deltas[i]
+		a.races++
+	}
+}
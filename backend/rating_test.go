@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func raceOf(entries ...RaceEntry) RaceRecord {
+	return RaceRecord{Results: entries}
+}
+
+func TestRatingsRecord(t *testing.T) {
+	tests := []struct {
+		name string
+		race RaceRecord
+		want map[string]float64
+	}{
+		{
+			"alone",
+			raceOf(RaceEntry{Name: "a", Placement: 1}),
+			map[string]float64{"a": initialRating},
+		},
+		// even players swap half of a provisional K
+		{
+			"pair",
+			raceOf(RaceEntry{Name: "a", Placement: 1}, RaceEntry{Name: "b", Placement: 2}),
+			map[string]float64{"a": initialRating + provisionalK/2, "b": initialRating - provisionalK/2},
+		},
+		{
+			"tie",
+			raceOf(RaceEntry{Name: "a", Placement: 1}, RaceEntry{Name: "b", Placement: 1}),
+			map[string]float64{"a": initialRating, "b": initialRating},
+		},
+		// K is shared over the n-1 games, so the winner of three gains the same
+		{
+			"three",
+			raceOf(
+				RaceEntry{Name: "a", Placement: 1},
+				RaceEntry{Name: "b", Placement: 2},
+				RaceEntry{Name: "c", Placement: 3},
+			),
+			map[string]float64{"a": initialRating + provisionalK/2, "b": initialRating, "c": initialRating - provisionalK/2},
+		},
+		{
+			"bots skipped",
+			raceOf(RaceEntry{Name: "a", Placement: 2}, RaceEntry{Name: "bot", Placement: 1, Bot: true}),
+			map[string]float64{"a": initialRating, "bot": initialRating},
+		},
+		{
+			"names ignore case",
+			raceOf(RaceEntry{Name: "A", Placement: 1}, RaceEntry{Name: " b ", Placement: 2}),
+			map[string]float64{"a": initialRating + provisionalK/2, "B": initialRating - provisionalK/2},
+		},
+	}
+
+	for _, tt := range tests {
+		r := NewRatings([]RaceRecord{tt.race})
+		for name, want := range tt.want {
+			if got := r.Get(name); math.Abs(got-want) > 1e-9 {
+				t.Errorf("%s: Get(%q) = %v, want %v", tt.name, name, got, want)
+			}
+		}
+	}
+}
+
+func TestRatingsSettle(t *testing.T) {
+	pair := raceOf(RaceEntry{Name: "a", Placement: 1}, RaceEntry{Name: "b", Placement: 2})
+
+	r := NewRatings(nil)
+	var gain float64
+	for i := range provisionalRaces + 1 {
+		before := r.Get("a")
+		r.Record(pair)
+		gain = r.Get("a") - before
+
+		if gain <= 0 {
+			t.Fatalf("race %d: winner gained %v", i, gain)
+		}
+	}
+
+	// past the provisional races K drops, so wins count for less
+	want := establishedK / (1 + math.Pow(10, (r.Get("a")-gain-r.Get("b")-gain)/400))
+	if math.Abs(gain-want) > 1e-9 {
+		t.Errorf("established gain = %v, want %v", gain, want)
+	}
+}
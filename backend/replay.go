@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ---- Replay file format ----
+//
+// A replay is a gzipped stream: a header of magic, version, lobby id and the
+// unix millisecond the lobby opened, then records of
+//
+//	offset uint32 (ms since the lobby opened) | kind byte | len uint16 | payload
+//
+// Broadcast records hold a marshaled ServerMessage exactly as players got it,
+// which is all playback needs. The rest record what the lobby heard from its
+// clients, for working out afterwards why a race went the way it did.
+
+const replayMagic = "OTRP"
+const replayVersion byte = 1
+const replayExt = ".replay"
+
+type replayKind byte
+
+const (
+	replayBroadcast replayKind = iota
+	replaySkipWait
+	replayProgressUpdate
+	replayFinished
+	replayApplyStatusEffect
+	replayStatusChanged
+	replayPowerupUsed
+	replayEffectReceived
+	replayResumed
+	replayLeft
+)
+
+type replayRecord struct {
+	offset  time.Duration
+	kind    replayKind
+	payload []byte
+}
+
+// replayRecorder is owned by its lobby's goroutine
+type replayRecorder struct {
+	lobbyId int
+	start   time.Time
+	records []replayRecord
+}
+
+func newReplayRecorder(lobbyId int) *replayRecorder {
+	return &replayRecorder{
+		lobbyId: lobbyId,
+		start:   time.Now(),
+	}
+}
+
+func (r *replayRecorder) record(kind replayKind, payload []byte) {
+	r.records = append(r.records, replayRecord{
+		offset:  time.Since(r.start),
+		kind:    kind,
+		payload: payload,
+	})
+}
+
+func (r *replayRecorder) broadcast(msg ServerMessage) {
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		return
+	}
+	r.record(replayBroadcast, data)
+}
+
+func (r *replayRecorder) event(msg ClientLobbyMessage) {
+	var buf bytes.Buffer
+	var kind replayKind
+
+	switch msg := msg.(type) {
+	case ClientLobbySkipWait:
+		kind = replaySkipWait
+
+	case ClientLobbyProgressUpdate:
+		kind = replayProgressUpdate
+		buf.WriteByte(msg.clientId)
+		binary.Write(&buf, binary.BigEndian, math.Float32bits(msg.progress))
+		binary.Write(&buf, binary.BigEndian, uint32(msg.wpm))
+		binary.Write(&buf, binary.BigEndian, uint32(msg.rawWpm))
+		binary.Write(&buf, binary.BigEndian, math.Float32bits(msg.accuracy))
+		binary.Write(&buf, binary.BigEndian, uint32(msg.words))
+
+	case ClientLobbyFinished:
+		kind = replayFinished
+		buf.WriteByte(msg.clientId)
+		binary.Write(&buf, binary.BigEndian, uint32(msg.wpm))
+		binary.Write(&buf, binary.BigEndian, math.Float32bits(msg.accuracy))
+
+	case ClientLobbyApplyStatusEffect:
+		kind = replayApplyStatusEffect
+		buf.Write([]byte{msg.fromClientId, msg.affectedClientId, msg.powerupId})
+
+	case ClientLobbyStatusChanged:
+		kind = replayStatusChanged
+		buf.WriteByte(msg.clientId)
+		ids := msg.powerupIds()
+		buf.WriteByte(byte(len(ids)))
+		buf.Write(ids)
+
+	case ClientLobbyPowerupUsed:
+		kind = replayPowerupUsed
+		buf.Write([]byte{msg.clientId, msg.powerupId})
+
+	case ClientLobbyEffectReceived:
+		kind = replayEffectReceived
+		buf.Write([]byte{msg.clientId, msg.fromClientId, msg.powerupId})
+
+	case ClientLobbyResumed:
+		kind = replayResumed
+		buf.WriteByte(msg.clientId)
+		binary.Write(&buf, binary.BigEndian, uint32(msg.idx))
+
+	default:
+		return
+	}
+
+	r.record(kind, buf.Bytes())
+}
+
+func (r *replayRecorder) left(clientId ClientId) {
+	r.record(replayLeft, []byte{clientId})
+}
+
+func (r *replayRecorder) id() string {
+	return fmt.Sprintf("%d-%d", r.start.UnixMilli(), r.lobbyId)
+}
+
+func (r *replayRecorder) save(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(dir, r.id()+replayExt))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	w := bufio.NewWriter(zw)
+
+	w.WriteString(replayMagic)
+	w.WriteByte(replayVersion)
+	binary.Write(w, binary.BigEndian, uint32(r.lobbyId))
+	binary.Write(w, binary.BigEndian, r.start.UnixMilli())
+
+	for _, rec := range r.records {
+		if len(rec.payload) > math.MaxUint16 {
+			continue
+		}
+		binary.Write(w, binary.BigEndian, uint32(rec.offset/time.Millisecond))
+		w.WriteByte(byte(rec.kind))
+		binary.Write(w, binary.BigEndian, uint16(len(rec.payload)))
+		w.Write(rec.payload)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func readReplay(rd io.Reader) ([]replayRecord, error) {
+	zr, err := gzip.NewReader(rd)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(zr)
+
+	header := make([]byte, len(replayMagic)+1+4+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("replay: reading header: %w", err)
+	}
+	if string(header[:len(replayMagic)]) != replayMagic {
+		return nil, fmt.Errorf("replay: not a replay file")
+	}
+	if v := header[len(replayMagic)]; v != replayVersion {
+		return nil, fmt.Errorf("replay: unsupported version %d", v)
+	}
+
+	var records []replayRecord
+	head := make([]byte, 4+1+2)
+	for {
+		if _, err := io.ReadFull(r, head); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+			return nil, fmt.Errorf("replay: reading record: %w", err)
+		}
+
+		rec := replayRecord{
+			offset: time.Duration(binary.BigEndian.Uint32(head[0:4])) * time.Millisecond,
+			kind:   replayKind(head[4]),
+		}
+		rec.payload = make([]byte, binary.BigEndian.Uint16(head[5:7]))
+		if _, err := io.ReadFull(r, rec.payload); err != nil {
+			return nil, fmt.Errorf("replay: reading record: %w", err)
+		}
+		records = append(records, rec)
+	}
+}
+
+// ---- Replay store ----
+
+var replayIdPattern = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+
+type replayStore struct {
+	dir string
+}
+
+func (s replayStore) path(id string) (string, bool) {
+	if !replayIdPattern.MatchString(id) {
+		return "", false
+	}
+	return filepath.Join(s.dir, id+replayExt), true
+}
+
+func (s replayStore) list() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), replayExt); ok && replayIdPattern.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids, nil
+}
+
+func (s replayStore) open(id string) ([]replayRecord, error) {
+	path, ok := s.path(id)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readReplay(f)
+}
+
+// ---- HTTP ----
+
+func (s replayStore) ServeList(w http.ResponseWriter, r *http.Request) {
+	ids, err := s.list()
+	if err != nil {
+		log.Printf("listing replays: %s", err)
+		http.Error(w, "could not list replays", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ids)
+}
+
+func (s replayStore) ServeReplay(w http.ResponseWriter, r *http.Request) {
+	path, ok := s.path(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeFile(w, r, path)
+}
+
+// ---- Playback ----
+
+// playReplay sends a replay's broadcasts down conn with their original
+// spacing divided by speed, so a client sees the race as a spectator did
+func (s replayStore) playReplay(conn *websocket.Conn, msg *WatchReplayMessage) {
+	defer conn.Close()
+
+	records, err := s.open(msg.ID)
+	if err != nil {
+		log.Printf("replay %s: %s", msg.ID, err)
+		if data, err := (JoinFailedMessage{Reason: JoinFailedNotFound}).MarshalBinary(); err == nil {
+			conn.WriteMessage(websocket.BinaryMessage, data)
+		}
+		conn.WriteMessage(websocket.CloseMessage, []byte{})
+		return
+	}
+
+	speed := max(float64(msg.Speed), 1)
+	log.Printf("replay %s: playing at %gx", msg.ID, speed)
+
+	// reading is the only way to notice the viewer leaving
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	for _, rec := range records {
+		if rec.kind != replayBroadcast {
+			continue
+		}
+
+		due := time.Duration(float64(rec.offset) / speed)
+		select {
+		case <-time.After(time.Until(start.Add(due))):
+		case <-stopped:
+			return
+		}
+
+		if err := conn.WriteMessage(websocket.BinaryMessage, rec.payload); err != nil {
+			log.Printf("replay %s: %s", msg.ID, err)
+			return
+		}
+	}
+
+	conn.WriteMessage(websocket.CloseMessage, []byte{})
+}
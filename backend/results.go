@@ -0,0 +1,191 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// playerResult is what the lobby remembers about each racer for the final
+// scoreboard, kept up to date from the messages the racers send it
+type playerResult struct {
+	id   ClientId
+	name string
+
+	finished     bool
+	disconnected bool
+	placement    byte
+	team         TeamId
+	bot          bool
+
+	progress   float32
+	wordsTyped int
+	wpm        uint32
+	rawWpm     uint32
+	accuracy   float32
+	timeTaken  time.Duration
+
+	// latest active effects and cooldowns, for replaying to resumed clients
+	effects   []effectStatus
+	cooldowns []cooldownStatus
+	// powerups from other racers don't land until then
+	immuneUntil time.Time
+	// a hit is on its way to them and they haven't taken or stopped it yet
+	pendingHit bool
+
+	powerupsUsed    []byte
+	effectsReceived []byte
+}
+
+func (l *Lobby) newResults() {
+	l.results = make(map[ClientId]*playerResult, len(l.clients))
+	for id, c := range l.clients {
+		l.results[id] = &playerResult{
+			id:       id,
+			name:     c.name,
+			accuracy: 1,
+			bot:      c.bot != nil,
+		}
+	}
+}
+
+// standings orders finishers by placement, then everyone who didn't finish
+// by how far they got, and hands out the remaining placements in that order.
+// Time attack races are ranked by words completed instead.
+func (l *Lobby) standings() []*playerResult {
+	rs := make([]*playerResult, 0, len(l.results))
+	for _, r := range l.results {
+		rs = append(rs, r)
+	}
+
+	if l.timeAttack > 0 {
+		return timeAttackStandings(rs)
+	}
+
+	sort.Slice(rs, func(i, j int) bool {
+		a, b := rs[i], rs[j]
+		if a.finished != b.finished {
+			return a.finished
+		}
+		if a.finished {
+			return a.placement < b.placement
+		}
+		if a.progress != b.progress {
+			return a.progress > b.progress
+		}
+		return a.id < b.id
+	})
+
+	for i, r := range rs {
+		if !r.finished {
+			r.placement = byte(i + 1)
+		}
+	}
+
+	return rs
+}
+
+func timeAttackStandings(rs []*playerResult) []*playerResult {
+	sort.Slice(rs, func(i, j int) bool {
+		a, b := rs[i], rs[j]
+		if a.finished != b.finished {
+			return a.finished
+		}
+		if a.wordsTyped != b.wordsTyped {
+			return a.wordsTyped > b.wordsTyped
+		}
+		if a.wpm != b.wpm {
+			return a.wpm > b.wpm
+		}
+		return a.id < b.id
+	})
+
+	for i, r := range rs {
+		r.placement = byte(i + 1)
+	}
+
+	return rs
+}
+
+func (r *playerResult) status() ResultStatus {
+	switch {
+	case r.finished:
+		return ResultFinished
+	case r.disconnected:
+		return ResultDisconnected
+	default:
+		return ResultDNF
+	}
+}
+
+func (l *Lobby) raceResults() RaceResultsMessage {
+	standings := l.standings()
+	msg := RaceResultsMessage{
+		Results:    make([]PlayerResult, 0, len(standings)),
+		WordsTyped: make([]uint32, 0, len(standings)),
+	}
+
+	for _, r := range standings {
+		msg.Results = append(msg.Results, PlayerResult{
+			PlayerID:        r.id,
+			Placement:       r.placement,
+			Status:          r.status(),
+			WPM:             r.wpm,
+			Accuracy:        r.accuracy,
+			TimeTaken:       uint32(r.timeTaken / time.Millisecond),
+			PowerupsUsed:    r.powerupsUsed,
+			EffectsReceived: r.effectsReceived,
+		})
+		msg.WordsTyped = append(msg.WordsTyped, uint32(r.wordsTyped))
+	}
+
+	return msg
+}
+
+// teamNumber counts teams from 1 for the store, 0 is no team
+func (l *Lobby) teamNumber(r *playerResult) int {
+	if l.teams == 0 {
+		return 0
+	}
+	return int(r.team) + 1
+}
+
+func powerupNameList(ids []byte) []string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = PowerupId(id).String()
+	}
+	return names
+}
+
+// raceRecord is the race as it goes into the store, keyed by the replay's id
+func (l *Lobby) raceRecord() RaceRecord {
+	standings := l.standings()
+	race := RaceRecord{
+		ID:         l.replay.id(),
+		LobbyID:    l.id,
+		Private:    l.private,
+		Words:      l.source.Name(),
+		TimeAttack: int(l.timeAttack),
+		StartedAt:  l.raceStart,
+		Results:    make([]RaceEntry, 0, len(standings)),
+	}
+
+	for _, r := range standings {
+		race.Results = append(race.Results, RaceEntry{
+			Name:            r.name,
+			Placement:       int(r.placement),
+			Status:          r.status().String(),
+			WPM:             int(r.wpm),
+			RawWPM:          int(r.rawWpm),
+			WordsTyped:      r.wordsTyped,
+			Team:            l.teamNumber(r),
+			Bot:             r.bot,
+			Accuracy:        r.accuracy,
+			TimeTakenMs:     r.timeTaken.Milliseconds(),
+			PowerupsUsed:    powerupNameList(r.powerupsUsed),
+			EffectsReceived: powerupNameList(r.effectsReceived),
+		})
+	}
+
+	return race
+}
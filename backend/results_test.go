@@ -0,0 +1,102 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// placings is each result's id, in the order given, and the placement it got
+func placings(rs []*playerResult) [][2]int {
+	out := make([][2]int, len(rs))
+	for i, r := range rs {
+		out[i] = [2]int{int(r.id), int(r.placement)}
+	}
+	return out
+}
+
+func resultsOf(rs ...*playerResult) map[ClientId]*playerResult {
+	m := make(map[ClientId]*playerResult, len(rs))
+	for _, r := range rs {
+		m[r.id] = r
+	}
+	return m
+}
+
+func TestStandings(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []*playerResult
+		want    [][2]int
+	}{
+		{"empty", nil, [][2]int{}},
+		{
+			"finishers keep their placements",
+			[]*playerResult{
+				{id: 1, finished: true, placement: 2},
+				{id: 2, finished: true, placement: 1},
+			},
+			[][2]int{{2, 1}, {1, 2}},
+		},
+		{
+			"the rest by progress",
+			[]*playerResult{
+				{id: 1, progress: 0.2},
+				{id: 2, finished: true, placement: 1},
+				{id: 3, progress: 0.7},
+				{id: 4, progress: 0.7},
+				{id: 5, progress: 0.9, disconnected: true},
+			},
+			[][2]int{{2, 1}, {5, 2}, {3, 3}, {4, 4}, {1, 5}},
+		},
+	}
+
+	for _, tt := range tests {
+		l := &Lobby{results: resultsOf(tt.results...)}
+		if got := placings(l.standings()); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: standings = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTimeAttackStandings(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []*playerResult
+		want    [][2]int
+	}{
+		{
+			"most words wins",
+			[]*playerResult{
+				{id: 1, wordsTyped: 10, wpm: 90},
+				{id: 2, wordsTyped: 30, wpm: 40},
+				{id: 3, wordsTyped: 20, wpm: 60},
+			},
+			[][2]int{{2, 1}, {3, 2}, {1, 3}},
+		},
+		{
+			"ties go to wpm, then id",
+			[]*playerResult{
+				{id: 3, wordsTyped: 20, wpm: 50},
+				{id: 1, wordsTyped: 20, wpm: 50},
+				{id: 2, wordsTyped: 20, wpm: 70},
+			},
+			[][2]int{{2, 1}, {1, 2}, {3, 3}},
+		},
+		// running out of words before the clock beats any count
+		{
+			"finishers first",
+			[]*playerResult{
+				{id: 1, wordsTyped: 80},
+				{id: 2, wordsTyped: 50, finished: true, placement: 4},
+			},
+			[][2]int{{2, 1}, {1, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		l := &Lobby{results: resultsOf(tt.results...), timeAttack: 60}
+		if got := placings(l.standings()); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: standings = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+const resumeTokenBytes = 16
+
+// sessionRegistry maps resume tokens to clients still holding a place in a
+// lobby. It's shared between ServeWs goroutines and lobbies, so unlike the
+// rest of the hub it is guarded by a mutex rather than owned by Run.
+type sessionRegistry struct {
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+func newSessionRegistry() *sessionRegistry {
+	return &sessionRegistry{
+		clients: make(map[string]*Client),
+	}
+}
+
+func (s *sessionRegistry) add(c *Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[c.resumeToken] = c
+}
+
+func (s *sessionRegistry) get(token string) (*Client, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.clients[token]
+	return c, ok
+}
+
+func (s *sessionRegistry) remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, token)
+}
+
+func newResumeToken() string {
+	b := make([]byte, resumeTokenBytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -4,30 +4,57 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 )
 
-func Serve() {
-	mux := routes()
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+func Serve(configPath string) {
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		log.Fatal(err)
 	}
-	addr := fmt.Sprintf("0.0.0.0:%s", port)
+
+	store, err := OpenStore(cfg.DataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	words, err := loadWordSources(cfg.WordListDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := routes(cfg, store, words)
+	addr := fmt.Sprintf("0.0.0.0:%s", cfg.Port)
 	log.Printf("listening and serving on %s", addr)
 	http.ListenAndServe(addr, mux)
 }
 
-func routes() *http.ServeMux {
+func routes(cfg *Config, store Store, words *wordSources) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	fileServer := http.FileServer(http.Dir("../frontend/dist/"))
 	mux.Handle("/", fileServer)
 
-	hub := NewHub()
+	hub := NewHub(cfg, store, words)
 	go hub.Run()
 
 	mux.HandleFunc("/ws", hub.ServeWs)
 
+	mux.HandleFunc("GET /replays", hub.replays.ServeList)
+	mux.HandleFunc("GET /replays/{id}", hub.replays.ServeReplay)
+
+	mux.HandleFunc("GET /players/{name}", serveProfile(store))
+	mux.HandleFunc("GET /players/{name}/history", serveHistory(store))
+
+	mux.HandleFunc("GET /wordlists", words.ServeList)
+	mux.HandleFunc("PUT /wordlists/{name}", words.ServeUpload)
+
+	mux.HandleFunc("GET /leaderboards", hub.leaderboards.ServeAll)
+	mux.HandleFunc("GET /leaderboards/{metric}", hub.leaderboards.ServeMetric)
+
+	mux.HandleFunc("POST /tournaments", hub.tournaments.ServeCreate)
+	mux.HandleFunc("GET /tournaments", hub.tournaments.ServeList)
+	mux.HandleFunc("GET /tournaments/{id}", hub.tournaments.ServeBracket)
+	mux.HandleFunc("GET /tournaments/{id}/feed", hub.tournaments.ServeFeed)
+
 	return mux
 }
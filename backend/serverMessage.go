@@ -21,11 +21,25 @@ const (
 	OpcodeLobbyGreeting       ServerOpcode = 1
 	OpcodeNewRegisteredPlayer ServerOpcode = 2
 	OpcodeRaceStarted         ServerOpcode = 3
-	OpcodeProgressUpdate      ServerOpcode = 4
-	OpcodePlayerFinished      ServerOpcode = 5
-	OpcodeStatusChanged       ServerOpcode = 6
 	OpcodePurchaseResult      ServerOpcode = 7
 	OpcodeUpdateWords         ServerOpcode = 8
+	OpcodeLobbyCode           ServerOpcode = 9
+	OpcodeJoinFailed          ServerOpcode = 10
+	OpcodeSubmissionRejected  ServerOpcode = 11
+	OpcodeProgressUpdateV2    ServerOpcode = 12 // replaces 4, which isn't reused
+	OpcodePlayerFinishedV2    ServerOpcode = 13 // replaces 5, which isn't reused
+	OpcodeRaceResults         ServerOpcode = 14
+	OpcodeRaceCountdown       ServerOpcode = 15
+	OpcodeResumeState         ServerOpcode = 16
+	OpcodeSpectatorGreeting   ServerOpcode = 17
+	OpcodeQuote               ServerOpcode = 18
+	OpcodeTeamAssignment      ServerOpcode = 19
+	OpcodeTeamProgress        ServerOpcode = 20
+	OpcodeTeamResults         ServerOpcode = 21
+	OpcodeBalance             ServerOpcode = 22
+	OpcodePowerupCosts        ServerOpcode = 23
+	OpcodeStatusChangedV2     ServerOpcode = 24 // replaces 6, which isn't reused
+	OpcodeWordsSkipped        ServerOpcode = 25
 )
 
 // ---- Helper types ----
@@ -63,6 +77,9 @@ type LobbyGreetingMessage struct {
 	Players       []Player
 	Words         []string
 	Powerups      []int
+	// appended last so older clients can ignore them
+	ResumeToken string
+	LobbyID     uint32
 }
 
 func (m LobbyGreetingMessage) Opcode() byte {
@@ -107,6 +124,17 @@ func (m LobbyGreetingMessage) MarshalBinary() ([]byte, error) {
 		buf.WriteByte(byte(powerup))
 	}
 
+	if len(m.ResumeToken) > 255 {
+		return nil, fmt.Errorf("resume token too long")
+	}
+
+	buf.WriteByte(byte(len(m.ResumeToken)))
+	buf.WriteString(m.ResumeToken)
+
+	if err := binary.Write(&buf, binary.BigEndian, m.LobbyID); err != nil {
+		return nil, err
+	}
+
 	return buf.Bytes(), nil
 }
 
@@ -141,18 +169,152 @@ func (m RaceStartedMessage) MarshalBinary() ([]byte, error) {
 	return []byte{m.Opcode()}, nil
 }
 
-// ---- Progress Update (Opcode 4) ----
-type ProgressUpdateMessage struct {
+// ---- Purchase Result (Opcode 7) ----
+// balance is what's left after the purchase, or all of it if it failed
+type PurchaseResultMessage struct {
+	powerupID byte
+	success   bool
+	balance   uint32
+}
+
+func (PurchaseResultMessage) Opcode() byte {
+	return byte(OpcodePurchaseResult)
+}
+
+func (m PurchaseResultMessage) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+	buf.WriteByte(m.powerupID)
+	if m.success {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+
+	if err := binary.Write(&buf, binary.BigEndian, m.balance); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ---- Status Changed (Opcode 8) ----
+type UpdateWordsMessage struct {
+	idx   uint32
+	words []string
+}
+
+func (UpdateWordsMessage) Opcode() byte {
+	return byte(OpcodeUpdateWords)
+}
+
+func (m UpdateWordsMessage) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+
+	if err := binary.Write(&buf, binary.BigEndian, m.idx); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(&buf, binary.BigEndian, uint32(len(m.words))); err != nil {
+		return nil, err
+	}
+
+	for _, w := range m.words {
+		if len(w) > 255 {
+			return nil, fmt.Errorf("word too long")
+		}
+
+		buf.WriteByte(byte(len(w)))
+		buf.WriteString(w)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ---- Lobby Code (Opcode 9) ----
+type LobbyCodeMessage struct {
+	Code string
+}
+
+func (LobbyCodeMessage) Opcode() byte {
+	return byte(OpcodeLobbyCode)
+}
+
+func (m LobbyCodeMessage) MarshalBinary() ([]byte, error) {
+	if len(m.Code) > 255 {
+		return nil, fmt.Errorf("lobby code too long")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+	buf.WriteByte(byte(len(m.Code)))
+	buf.WriteString(m.Code)
+
+	return buf.Bytes(), nil
+}
+
+// ---- Join Failed (Opcode 10) ----
+type JoinFailedReason byte
+
+const (
+	JoinFailedNotFound JoinFailedReason = iota
+	JoinFailedFull
+	JoinFailedStarted
+	JoinFailedWordSource
+	JoinFailedMode
+	// tournament heats only take the players seeded into them
+	JoinFailedNotSeeded
+)
+
+type JoinFailedMessage struct {
+	Reason JoinFailedReason
+}
+
+func (JoinFailedMessage) Opcode() byte {
+	return byte(OpcodeJoinFailed)
+}
+
+func (m JoinFailedMessage) MarshalBinary() ([]byte, error) {
+	return []byte{m.Opcode(), byte(m.Reason)}, nil
+}
+
+// ---- Submission Rejected (Opcode 11) ----
+// Index is the word the server is still waiting on
+type SubmissionRejectedMessage struct {
+	Index uint32
+}
+
+func (SubmissionRejectedMessage) Opcode() byte {
+	return byte(OpcodeSubmissionRejected)
+}
+
+func (m SubmissionRejectedMessage) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+
+	if err := binary.Write(&buf, binary.BigEndian, m.Index); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ---- Progress Update V2 (Opcode 12) ----
+// supersedes opcode 4, adding raw WPM and accuracy
+type ProgressUpdateV2Message struct {
 	PlayerID byte
 	Progress float32
 	WPM      uint32
+	RawWPM   uint32
+	Accuracy float32
 }
 
-func (m ProgressUpdateMessage) Opcode() byte {
-	return byte(OpcodeProgressUpdate)
+func (ProgressUpdateV2Message) Opcode() byte {
+	return byte(OpcodeProgressUpdateV2)
 }
 
-func (m ProgressUpdateMessage) MarshalBinary() ([]byte, error) {
+func (m ProgressUpdateV2Message) MarshalBinary() ([]byte, error) {
 	var buf bytes.Buffer
 	buf.WriteByte(m.Opcode())
 	buf.WriteByte(m.PlayerID)
@@ -165,99 +327,530 @@ func (m ProgressUpdateMessage) MarshalBinary() ([]byte, error) {
 		return nil, err
 	}
 
+	if err := binary.Write(&buf, binary.BigEndian, m.RawWPM); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(&buf, binary.BigEndian, math.Float32bits(m.Accuracy)); err != nil {
+		return nil, err
+	}
+
 	return buf.Bytes(), nil
 }
 
-// ---- Player Finished (Opcode 5) ----
-type PlayerFinishedMessage struct {
+// ---- Player Finished V2 (Opcode 13) ----
+// supersedes opcode 5, adding final WPM and accuracy
+type PlayerFinishedV2Message struct {
 	PlayerID  byte
 	Placement byte
+	WPM       uint32
+	Accuracy  float32
 }
 
-func (m PlayerFinishedMessage) Opcode() byte {
-	return byte(OpcodePlayerFinished)
+func (PlayerFinishedV2Message) Opcode() byte {
+	return byte(OpcodePlayerFinishedV2)
 }
 
-func (m PlayerFinishedMessage) MarshalBinary() ([]byte, error) {
-	return []byte{m.Opcode(), m.PlayerID, m.Placement}, nil
+func (m PlayerFinishedV2Message) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+	buf.WriteByte(m.PlayerID)
+	buf.WriteByte(m.Placement)
+
+	if err := binary.Write(&buf, binary.BigEndian, m.WPM); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(&buf, binary.BigEndian, math.Float32bits(m.Accuracy)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ---- Race Results (Opcode 14) ----
+type ResultStatus byte
+
+const (
+	ResultFinished ResultStatus = iota
+	ResultDNF
+	ResultDisconnected
+)
+
+func (s ResultStatus) String() string {
+	switch s {
+	case ResultFinished:
+		return "finished"
+	case ResultDNF:
+		return "dnf"
+	case ResultDisconnected:
+		return "disconnected"
+	default:
+		return "unknown"
+	}
 }
 
-// ---- Status Changed (Opcode 6) ----
-type StatusChangedMessage struct {
+// the powerup lists are each counted with a uint16, a long race can see more
+// than fit in a byte
+type PlayerResult struct {
 	PlayerID        byte
-	StatusEffectIDs []byte
+	Placement       byte
+	Status          ResultStatus
+	WPM             uint32
+	Accuracy        float32
+	TimeTaken       uint32 // milliseconds
+	PowerupsUsed    []byte
+	EffectsReceived []byte
 }
 
-func (m StatusChangedMessage) Opcode() byte {
-	return byte(OpcodeStatusChanged)
+func (r PlayerResult) marshal(buf *bytes.Buffer) error {
+	buf.WriteByte(r.PlayerID)
+	buf.WriteByte(r.Placement)
+	buf.WriteByte(byte(r.Status))
+
+	if err := binary.Write(buf, binary.BigEndian, r.WPM); err != nil {
+		return err
+	}
+
+	if err := binary.Write(buf, binary.BigEndian, math.Float32bits(r.Accuracy)); err != nil {
+		return err
+	}
+
+	if err := binary.Write(buf, binary.BigEndian, r.TimeTaken); err != nil {
+		return err
+	}
+
+	for _, ids := range [][]byte{r.PowerupsUsed, r.EffectsReceived} {
+		// past the count's limit the rest go unreported rather than losing
+		// everyone's results
+		ids = ids[:min(len(ids), math.MaxUint16)]
+		if err := binary.Write(buf, binary.BigEndian, uint16(len(ids))); err != nil {
+			return err
+		}
+		buf.Write(ids)
+	}
+
+	return nil
+}
+
+// words completed by each player follow the results, in the same order, for
+// ranking time attack races
+type RaceResultsMessage struct {
+	Results    []PlayerResult
+	WordsTyped []uint32
 }
 
-func (m StatusChangedMessage) MarshalBinary() ([]byte, error) {
+func (RaceResultsMessage) Opcode() byte {
+	return byte(OpcodeRaceResults)
+}
+
+func (m RaceResultsMessage) MarshalBinary() ([]byte, error) {
 	var buf bytes.Buffer
 	buf.WriteByte(m.Opcode())
-	buf.WriteByte(m.PlayerID)
 
-	buf.WriteByte(byte(len(m.StatusEffectIDs)))
+	if len(m.Results) > 255 {
+		return nil, fmt.Errorf("too many results")
+	}
+
+	buf.WriteByte(byte(len(m.Results)))
+	for _, r := range m.Results {
+		if err := r.marshal(&buf); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(m.WordsTyped) > 255 {
+		return nil, fmt.Errorf("too many results")
+	}
 
-	for _, id := range m.StatusEffectIDs {
-		buf.WriteByte(id)
+	buf.WriteByte(byte(len(m.WordsTyped)))
+	for _, n := range m.WordsTyped {
+		if err := binary.Write(&buf, binary.BigEndian, n); err != nil {
+			return nil, err
+		}
 	}
 
 	return buf.Bytes(), nil
 }
 
-// ---- Status Changed (Opcode 7) ----
-type PurchaseResultMessage struct {
-	powerupID byte
-	success   bool
+// ---- Race Countdown (Opcode 15) ----
+// seconds left before unfinished players are marked DNF
+type RaceCountdownMessage struct {
+	TimeRemaining uint16
 }
 
-func (PurchaseResultMessage) Opcode() byte {
-	return byte(OpcodePurchaseResult)
+func (RaceCountdownMessage) Opcode() byte {
+	return byte(OpcodeRaceCountdown)
 }
 
-func (m PurchaseResultMessage) MarshalBinary() ([]byte, error) {
+func (m RaceCountdownMessage) MarshalBinary() ([]byte, error) {
 	var buf bytes.Buffer
 	buf.WriteByte(m.Opcode())
-	buf.WriteByte(m.powerupID)
-	if m.success {
+
+	if err := binary.Write(&buf, binary.BigEndian, m.TimeRemaining); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ---- Resume State (Opcode 16) ----
+// where a resumed client left off, sent after the lobby has been replayed
+type ResumeStateMessage struct {
+	Index    uint32
+	Powerups []byte
+}
+
+func (ResumeStateMessage) Opcode() byte {
+	return byte(OpcodeResumeState)
+}
+
+func (m ResumeStateMessage) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+
+	if err := binary.Write(&buf, binary.BigEndian, m.Index); err != nil {
+		return nil, err
+	}
+
+	if len(m.Powerups) > 255 {
+		return nil, fmt.Errorf("too many powerups")
+	}
+
+	buf.WriteByte(byte(len(m.Powerups)))
+	buf.Write(m.Powerups)
+
+	return buf.Bytes(), nil
+}
+
+// ---- Spectator Greeting (Opcode 17) ----
+// followed by the same race state a resumed player is sent
+type SpectatorGreetingMessage struct {
+	LobbyID       uint32
+	TimeRemaining uint16
+	RaceStarted   bool
+	Players       []Player
+}
+
+func (SpectatorGreetingMessage) Opcode() byte {
+	return byte(OpcodeSpectatorGreeting)
+}
+
+func (m SpectatorGreetingMessage) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+
+	if err := binary.Write(&buf, binary.BigEndian, m.LobbyID); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(&buf, binary.BigEndian, m.TimeRemaining); err != nil {
+		return nil, err
+	}
+
+	if m.RaceStarted {
 		buf.WriteByte(1)
 	} else {
 		buf.WriteByte(0)
 	}
 
+	buf.WriteByte(byte(len(m.Players)))
+	for _, p := range m.Players {
+		if err := p.marshal(&buf); err != nil {
+			return nil, err
+		}
+	}
+
 	return buf.Bytes(), nil
 }
 
-// ---- Status Changed (Opcode 8) ----
-type UpdateWordsMessage struct {
-	idx   uint32
-	words []string
+// ---- Quote (Opcode 18) ----
+// sent after the greeting in quote races, the passage itself is the words
+type QuoteMessage struct {
+	Author string
+	Source string
+	Length QuoteLength
 }
 
-func (UpdateWordsMessage) Opcode() byte {
-	return byte(OpcodeUpdateWords)
+func (QuoteMessage) Opcode() byte {
+	return byte(OpcodeQuote)
 }
 
-func (m UpdateWordsMessage) MarshalBinary() ([]byte, error) {
+func (m QuoteMessage) MarshalBinary() ([]byte, error) {
+	if len(m.Author) > 255 || len(m.Source) > 255 {
+		return nil, fmt.Errorf("quote attribution too long")
+	}
+
 	var buf bytes.Buffer
 	buf.WriteByte(m.Opcode())
+	buf.WriteByte(byte(len(m.Author)))
+	buf.WriteString(m.Author)
+	buf.WriteByte(byte(len(m.Source)))
+	buf.WriteString(m.Source)
+	buf.WriteByte(byte(m.Length))
 
-	if err := binary.Write(&buf, binary.BigEndian, m.idx); err != nil {
+	return buf.Bytes(), nil
+}
+
+// ---- Team Assignment (Opcode 19) ----
+type TeamMember struct {
+	PlayerID byte
+	Team     TeamId
+}
+
+type TeamAssignmentMessage struct {
+	Teams   byte
+	Members []TeamMember
+}
+
+func (TeamAssignmentMessage) Opcode() byte {
+	return byte(OpcodeTeamAssignment)
+}
+
+func (m TeamAssignmentMessage) MarshalBinary() ([]byte, error) {
+	if len(m.Members) > 255 {
+		return nil, fmt.Errorf("too many team members")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+	buf.WriteByte(m.Teams)
+
+	buf.WriteByte(byte(len(m.Members)))
+	for _, member := range m.Members {
+		buf.WriteByte(member.PlayerID)
+		buf.WriteByte(member.Team)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ---- Team Progress (Opcode 20) ----
+// averaged over the team's members
+type TeamProgressMessage struct {
+	Team     TeamId
+	Progress float32
+	WPM      uint32
+}
+
+func (TeamProgressMessage) Opcode() byte {
+	return byte(OpcodeTeamProgress)
+}
+
+func (m TeamProgressMessage) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+	buf.WriteByte(m.Team)
+
+	if err := binary.Write(&buf, binary.BigEndian, math.Float32bits(m.Progress)); err != nil {
 		return nil, err
 	}
 
-	if err := binary.Write(&buf, binary.BigEndian, uint32(len(m.words))); err != nil {
+	if err := binary.Write(&buf, binary.BigEndian, m.WPM); err != nil {
 		return nil, err
 	}
 
-	for _, w := range m.words {
-		if len(w) > 255 {
-			return nil, fmt.Errorf("word too long")
+	return buf.Bytes(), nil
+}
+
+// ---- Team Results (Opcode 21) ----
+// sent after RaceResults, best team first
+type TeamResult struct {
+	Team          TeamId
+	Placement     byte
+	Points        float32 // average over the team's members
+	Progress      float32
+	WPM           uint32
+	Finished      byte
+	BestPlacement byte
+}
+
+type TeamResultsMessage struct {
+	Results []TeamResult
+}
+
+func (TeamResultsMessage) Opcode() byte {
+	return byte(OpcodeTeamResults)
+}
+
+func (m TeamResultsMessage) MarshalBinary() ([]byte, error) {
+	if len(m.Results) > 255 {
+		return nil, fmt.Errorf("too many teams")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+
+	buf.WriteByte(byte(len(m.Results)))
+	for _, r := range m.Results {
+		buf.WriteByte(r.Team)
+		buf.WriteByte(r.Placement)
+
+		if err := binary.Write(&buf, binary.BigEndian, math.Float32bits(r.Points)); err != nil {
+			return nil, err
 		}
 
-		buf.WriteByte(byte(len(w)))
-		buf.WriteString(w)
+		if err := binary.Write(&buf, binary.BigEndian, math.Float32bits(r.Progress)); err != nil {
+			return nil, err
+		}
+
+		if err := binary.Write(&buf, binary.BigEndian, r.WPM); err != nil {
+			return nil, err
+		}
+
+		buf.WriteByte(r.Finished)
+		buf.WriteByte(r.BestPlacement)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ---- Balance (Opcode 22) ----
+// sent whenever a racer's coins or streak change
+type BalanceMessage struct {
+	Balance uint32
+	Streak  uint16
+}
+
+func (BalanceMessage) Opcode() byte {
+	return byte(OpcodeBalance)
+}
+
+func (m BalanceMessage) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+
+	if err := binary.Write(&buf, binary.BigEndian, m.Balance); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(&buf, binary.BigEndian, m.Streak); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ---- Powerup Costs (Opcode 23) ----
+type PowerupCost struct {
+	PowerupID byte
+	Cost      uint16
+}
+
+type PowerupCostsMessage struct {
+	Costs []PowerupCost
+}
+
+func (PowerupCostsMessage) Opcode() byte {
+	return byte(OpcodePowerupCosts)
+}
+
+func (m PowerupCostsMessage) MarshalBinary() ([]byte, error) {
+	if len(m.Costs) > 255 {
+		return nil, fmt.Errorf("too many powerup costs")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+
+	buf.WriteByte(byte(len(m.Costs)))
+	for _, c := range m.Costs {
+		buf.WriteByte(c.PowerupID)
+		if err := binary.Write(&buf, binary.BigEndian, c.Cost); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ---- Status Changed V2 (Opcode 24) ----
+// each effect with who it came from and what's left of it, how long the
+// player can't be hit, and how long until they can use each powerup again
+type StatusChangedV2Message struct {
+	PlayerID  byte
+	Effects   []EffectState
+	Immunity  uint32
+	Cooldowns []CooldownState
+}
+
+// Remaining is milliseconds for timed effects and words for the rest
+type EffectState struct {
+	PowerupID byte
+	Source    byte
+	Intensity byte
+	Kind      DurationKind
+	Remaining uint32
+}
+
+// Remaining is milliseconds
+type CooldownState struct {
+	PowerupID byte
+	Remaining uint32
+}
+
+func (StatusChangedV2Message) Opcode() byte {
+	return byte(OpcodeStatusChangedV2)
+}
+
+func (m StatusChangedV2Message) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+	buf.WriteByte(m.PlayerID)
+
+	buf.WriteByte(byte(len(m.Effects)))
+	for _, e := range m.Effects {
+		buf.Write([]byte{e.PowerupID, e.Source, e.Intensity, byte(e.Kind)})
+		if err := binary.Write(&buf, binary.BigEndian, e.Remaining); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := binary.Write(&buf, binary.BigEndian, m.Immunity); err != nil {
+		return nil, err
+	}
+
+	buf.WriteByte(byte(len(m.Cooldowns)))
+	for _, cd := range m.Cooldowns {
+		buf.WriteByte(cd.PowerupID)
+		if err := binary.Write(&buf, binary.BigEndian, cd.Remaining); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// StatusEffectIDs is the ids of the effects on the player
+func (m StatusChangedV2Message) StatusEffectIDs() []byte {
+	ids := make([]byte, 0, len(m.Effects))
+	for _, e := range m.Effects {
+		ids = append(ids, e.PowerupID)
+	}
+	return ids
+}
+
+// ---- Words Skipped (Opcode 25) ----
+// the words from From up to To were typed for the player, who is on To now
+type WordsSkippedMessage struct {
+	From uint32
+	To   uint32
+}
+
+func (WordsSkippedMessage) Opcode() byte {
+	return byte(OpcodeWordsSkipped)
+}
+
+func (m WordsSkippedMessage) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(m.Opcode())
+
+	if err := binary.Write(&buf, binary.BigEndian, m.From); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(&buf, binary.BigEndian, m.To); err != nil {
+		return nil, err
 	}
 
 	return buf.Bytes(), nil
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ---- Code snippets ----
+//
+// Code races type a function from snippets/<name>.<language>.snippet. Tokens
+// are split on whitespace like words, and each line break is a unit of its
+// own holding the newlines and the next line's indentation, so layout is
+// typed and checked like everything else. See isLayout. Clients submit a line
+// break as the whole unit or as a bare "\n", the Enter press that types it in
+// an editor which indents for them.
+
+//go:embed snippets/*.snippet
+var snippetFiles embed.FS
+
+const codeSourceName = "code"
+
+// file extensions and the names a lobby creator can use for them
+var snippetLanguages = map[string]string{
+	"go":         "go",
+	"golang":     "go",
+	"py":         "python",
+	"python":     "python",
+	"js":         "javascript",
+	"javascript": "javascript",
+}
+
+type snippet struct {
+	name     string
+	language string
+	units    []string
+}
+
+type snippetCorpus struct {
+	byLanguage map[string][]snippet
+}
+
+func loadSnippets() (*snippetCorpus, error) {
+	c := &snippetCorpus{byLanguage: make(map[string][]snippet)}
+
+	files, err := snippetFiles.ReadDir("snippets")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		name, ext, ok := strings.Cut(strings.TrimSuffix(f.Name(), ".snippet"), ".")
+		language, known := snippetLanguages[ext]
+		if !ok || !known {
+			return nil, fmt.Errorf("snippet %s: expected <name>.<go|py|js>.snippet", f.Name())
+		}
+
+		data, err := snippetFiles.ReadFile(path.Join("snippets", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		units := codeUnits(string(data))
+		if len(units) == 0 {
+			return nil, fmt.Errorf("snippet %s is empty", f.Name())
+		}
+		for _, u := range units {
+			if len(u) > 255 {
+				return nil, fmt.Errorf("snippet %s: %.16q... is longer than 255 bytes", f.Name(), u)
+			}
+		}
+
+		c.byLanguage[language] = append(c.byLanguage[language], snippet{
+			name:     name,
+			language: language,
+			units:    units,
+		})
+	}
+
+	return c, nil
+}
+
+// codeUnits splits source into tokens and line breaks, dropping trailing
+// whitespace and folding blank lines into the break before the next line
+func codeUnits(source string) []string {
+	var units []string
+	newlines := 0
+
+	for _, line := range strings.Split(source, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			newlines++
+			continue
+		}
+
+		if len(units) > 0 {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			units = append(units, strings.Repeat("\n", newlines)+indent)
+		}
+		newlines = 1
+
+		units = append(units, fields...)
+	}
+
+	return units
+}
+
+// matchesUnit reports whether word is a submission of unit, see above for
+// line breaks. Homoglyphs are typed as the letters they look like.
+func matchesUnit(unit, word string) bool {
+	return word == unit || word == plainText(unit) || isLayout(unit) && word == "\n"
+}
+
+// languages are the programming languages with snippets, sorted
+func (c *snippetCorpus) languages() []string {
+	languages := make([]string, 0, len(c.byLanguage))
+	for l := range c.byLanguage {
+		languages = append(languages, l)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// resolve picks a snippet in language, any language if it's empty
+func (c *snippetCorpus) resolve(language string) (WordSource, error) {
+	var snippets []snippet
+	if language == "" {
+		for _, s := range c.byLanguage {
+			snippets = append(snippets, s...)
+		}
+	} else {
+		snippets = c.byLanguage[snippetLanguages[strings.ToLower(language)]]
+	}
+
+	if len(snippets) == 0 {
+		return nil, fmt.Errorf("no code snippets for language %q", language)
+	}
+
+	s := snippets[rand.Intn(len(snippets))]
+	return &codeSource{snippet: s}, nil
+}
+
+// codeSource is one snippet, picked when the lobby is made
+type codeSource struct {
+	snippet snippet
+}
+
+func (c *codeSource) Name() string {
+	return codeSourceName + ":" + c.snippet.language
+}
+
+// Words draws tokens from the snippet, never line breaks, for powerups that
+// add words mid-race
+func (c *codeSource) Words(n int) []string {
+	var tokens []string
+	for _, u := range c.snippet.units {
+		if !isLayout(u) {
+			tokens = append(tokens, u)
+		}
+	}
+	return RandomWords(tokens, n)
+}
+
+func (c *codeSource) Passage() []string {
+	return c.snippet.units
+}
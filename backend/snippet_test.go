@@ -0,0 +1,54 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCodeUnits(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"", nil},
+		{"x := 1", []string{"x", ":=", "1"}},
+		{"if x {\n\treturn\n}", []string{"if", "x", "{", "\n\t", "return", "\n", "}"}},
+		// blank lines fold into the next line break
+		{"a\n\n\nb", []string{"a", "\n\n\n", "b"}},
+		// leading and trailing blank lines are dropped
+		{"\n\n  a\n\n", []string{"a"}},
+		{"a\n    b", []string{"a", "\n    ", "b"}},
+	}
+
+	for _, tt := range tests {
+		if got := codeUnits(tt.source); !slices.Equal(got, tt.want) {
+			t.Errorf("codeUnits(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesUnit(t *testing.T) {
+	tests := []struct {
+		unit, word string
+		want       bool
+	}{
+		{"return", "return", true},
+		{"return", "retrun", false},
+		{"\n\t", "\n\t", true},
+		// Enter types a line break whatever its indent
+		{"\n\t", "\n", true},
+		{"\n\n", "\n", true},
+		{"return", "\n", false},
+		{"\n\t", "", false},
+		// homoglyphs are typed as plain letters
+		{"t\u0435st", "test", true},
+		{"t\u0435st", "t\u0435st", true},
+		{"t\u0435st", "tast", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesUnit(tt.unit, tt.word); got != tt.want {
+			t.Errorf("matchesUnit(%q, %q) = %v, want %v", tt.unit, tt.word, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+// spectate is a spectator's read side. Spectators share a player's write side
+// but never get a state handler or an id, so nothing they send reaches the
+// lobby; reading only tells us when they leave.
+func (c *Client) spectate() {
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	c.log("spectator left")
+	close(c.gone)
+
+	select {
+	case c.unwatch <- c:
+	case <-c.lobbyDone:
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import "time"
+
+// typingStats is a player's running tally for the race. Correct characters
+// only come from verified submissions; mistakes are whatever the client
+// reports plus every submission the server turned down.
+type typingStats struct {
+	charsTyped int
+	incorrect  int
+	backspaces int
+	rejected   int
+}
+
+func (s typingStats) errors() int {
+	return s.incorrect + s.rejected
+}
+
+// accuracy is the share of keystrokes that were correct, from 0 to 1
+func (s typingStats) accuracy() float32 {
+	total := s.charsTyped + s.errors()
+	if total == 0 {
+		return 1
+	}
+	return float32(s.charsTyped) / float32(total)
+}
+
+// wpm only counts characters that made it into accepted words
+func (s typingStats) wpm(elapsed time.Duration) float32 {
+	return wordsPerMinute(s.charsTyped, elapsed)
+}
+
+// rawWpm counts every character typed, mistakes included
+func (s typingStats) rawWpm(elapsed time.Duration) float32 {
+	return wordsPerMinute(s.charsTyped+s.incorrect, elapsed)
+}
+
+// typedLength is how many characters typing words[i] takes, counting the
+// space after it. Spaces only go between two words, not next to a line break.
+// A line break is a single Enter however deep its indentation goes.
+func typedLength(words []string, i int) int {
+	if isLayout(words[i]) {
+		return 1
+	}
+	n := len(words[i])
+	if i < len(words)-1 && !isLayout(words[i+1]) {
+		n++
+	}
+	return n
+}
+
+// passageProgress is how much of words is typed once the first idx are done,
+// by characters so long words count for more than short ones. bonus is extra
+// characters on top, from nitro.
+func passageProgress(words []string, idx int, bonus float64) float32 {
+	typed, total := 0, 0
+	for i := range words {
+		n := typedLength(words, i)
+		if i < idx {
+			typed += n
+		}
+		total += n
+	}
+	if total == 0 {
+		return 1
+	}
+	return min(float32((float64(typed)+bonus)/float64(total)), 1)
+}
+
+// a word for wpm is five characters, whatever was typed
+const charsPerWord = 5
+
+func wordsPerMinute(chars int, elapsed time.Duration) float32 {
+	secSpentRacing := float32(elapsed) / float32(time.Second)
+	// Avoid division by zero
+	if secSpentRacing <= 0 {
+		secSpentRacing = 0.001
+	}
+	wordsCompleted := float32(chars) / charsPerWord
+	return 60 * wordsCompleted / secSpentRacing
+}
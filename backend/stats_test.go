@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTypedLength(t *testing.T) {
+	tests := []struct {
+		words []string
+		i     int
+		want  int
+	}{
+		{[]string{"ab", "cd"}, 0, 3},
+		{[]string{"ab", "cd"}, 1, 2},
+		{[]string{"if", "\n\t", "x"}, 0, 2},
+		{[]string{"if", "\n\t", "x"}, 1, 1},
+		{[]string{"if", "\n\t", "x"}, 2, 1},
+	}
+
+	for _, tt := range tests {
+		if got := typedLength(tt.words, tt.i); got != tt.want {
+			t.Errorf("typedLength(%q, %d) = %d, want %d", tt.words, tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestPassageProgress(t *testing.T) {
+	tests := []struct {
+		words []string
+		idx   int
+		bonus float64
+		want  float32
+	}{
+		{nil, 0, 0, 1},
+		{[]string{"ab", "cd"}, 0, 0, 0},
+		{[]string{"ab", "cd"}, 1, 0, 0.6},
+		{[]string{"ab", "cd"}, 2, 0, 1},
+		// nitro's bonus characters count on top, never past the end
+		{[]string{"ab", "cd"}, 1, 1, 0.8},
+		{[]string{"ab", "cd"}, 0, 10, 1},
+		{[]string{"a", "\n", "b"}, 2, 0, 2.0 / 3},
+	}
+
+	for _, tt := range tests {
+		got := passageProgress(tt.words, tt.idx, tt.bonus)
+		if math.Abs(float64(got-tt.want)) > 1e-6 {
+			t.Errorf("passageProgress(%q, %d, %v) = %v, want %v", tt.words, tt.idx, tt.bonus, got, tt.want)
+		}
+	}
+}
+
+func TestTypingStats(t *testing.T) {
+	tests := []struct {
+		stats    typingStats
+		elapsed  time.Duration
+		wpm      float32
+		rawWpm   float32
+		accuracy float32
+	}{
+		{typingStats{}, time.Minute, 0, 0, 1},
+		{typingStats{charsTyped: 50}, time.Minute, 10, 10, 1},
+		{typingStats{charsTyped: 50, incorrect: 25, backspaces: 25}, 30 * time.Second, 20, 30, 50.0 / 75},
+		{typingStats{charsTyped: 30, rejected: 10}, time.Minute, 6, 6, 0.75},
+	}
+
+	for _, tt := range tests {
+		if got := tt.stats.wpm(tt.elapsed); math.Abs(float64(got-tt.wpm)) > 1e-3 {
+			t.Errorf("%+v wpm = %v, want %v", tt.stats, got, tt.wpm)
+		}
+		if got := tt.stats.rawWpm(tt.elapsed); math.Abs(float64(got-tt.rawWpm)) > 1e-3 {
+			t.Errorf("%+v rawWpm = %v, want %v", tt.stats, got, tt.rawWpm)
+		}
+		if got := tt.stats.accuracy(); math.Abs(float64(got-tt.accuracy)) > 1e-6 {
+			t.Errorf("%+v accuracy = %v, want %v", tt.stats, got, tt.accuracy)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// ---- Status effects ----
+//
+// statusEffects are the powerups currently on a racer, kept by their state
+// handler. Timed effects share one timer in the state handler, set for
+// whichever runs out next; word-count effects count down as words are typed,
+// and instant ones are gone once they've landed.
+// Another hit of an effect that's still on follows its StackRule.
+
+type activeEffect struct {
+	powerup Powerup
+	from    ClientId
+
+	duration  EffectDuration
+	expires   time.Time
+	wordsLeft int
+
+	// how many hits are stacked up, see StackIntensity
+	intensity int
+	// how many times it's been extended, each adds less, see StackExtend
+	extensions int
+}
+
+// extendFalloff is how much less each extension adds than the one before
+const extendFalloff = 0.5
+
+type statusEffects struct {
+	// in the order they landed
+	active []*activeEffect
+}
+
+func (s *statusEffects) find(id PowerupId) (*activeEffect, bool) {
+	for _, e := range s.active {
+		if e.powerup.Id() == id {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// add puts an effect on, or stacks it if it's already on. It reports
+// whether the hit does anything beyond its duration, which is when its
+// transform and hooks should run.
+func (s *statusEffects) add(p Powerup, from ClientId, pc PowerupConfig, now time.Time) (*activeEffect, bool) {
+	e, ok := s.find(p.Id())
+	if !ok {
+		e = &activeEffect{powerup: p, from: from, intensity: 1}
+		e.start(p.Duration(pc), now)
+		s.active = append(s.active, e)
+		return e, true
+	}
+
+	e.from = from
+	rule := stacking(p)
+	switch rule.Mode {
+	case StackExtend:
+		e.extensions++
+		e.extend(p.Duration(pc), math.Pow(extendFalloff, float64(e.extensions)), now)
+		return e, true
+
+	case StackIntensity:
+		e.start(p.Duration(pc), now)
+		if e.intensity >= rule.MaxIntensity {
+			return e, false
+		}
+		e.intensity++
+		return e, true
+
+	default:
+		e.start(p.Duration(pc), now)
+		return e, true
+	}
+}
+
+func (e *activeEffect) start(d EffectDuration, now time.Time) {
+	e.duration = d
+	switch d.Kind {
+	case DurationTimed:
+		e.expires = now.Add(d.Time)
+	case DurationWords:
+		e.wordsLeft = d.Words
+	}
+}
+
+// extend adds scale of d to what's left, at least a word or a second
+func (e *activeEffect) extend(d EffectDuration, scale float64, now time.Time) {
+	switch d.Kind {
+	case DurationTimed:
+		e.expires = e.expires.Add(max(time.Duration(float64(d.Time)*scale), time.Second))
+		if e.expires.Before(now) {
+			e.expires = now
+		}
+	case DurationWords:
+		e.wordsLeft += max(int(float64(d.Words)*scale), 1)
+	}
+}
+
+func (s *statusEffects) remove(e *activeEffect) {
+	s.active = slices.DeleteFunc(s.active, func(a *activeEffect) bool { return a == e })
+}
+
+// debuffs are the effects on that came from someone else
+func (s *statusEffects) debuffs() []*activeEffect {
+	var debuffs []*activeEffect
+	for _, e := range s.active {
+		if !targetsSelf(e.powerup) {
+			debuffs = append(debuffs, e)
+		}
+	}
+	return debuffs
+}
+
+// progressMultiplier is how much every word typed counts for right now
+func (s *statusEffects) progressMultiplier(pc PowerupConfig) float64 {
+	m := 1.0
+	for _, e := range s.active {
+		if b, ok := e.powerup.(ProgressBooster); ok {
+			m *= b.ProgressMultiplier(pc)
+		}
+	}
+	return m
+}
+
+// absorber is the first effect on that will stop incoming
+func (s *statusEffects) absorber(incoming Powerup) (*activeEffect, bool) {
+	for _, e := range s.active {
+		if a, ok := e.powerup.(Absorber); ok && a.Absorbs(incoming) {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// reflector is the first effect on that will send incoming back
+func (s *statusEffects) reflector(incoming Powerup) (*activeEffect, bool) {
+	for _, e := range s.active {
+		if r, ok := e.powerup.(Reflector); ok && r.Reflects(incoming) {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// wordTyped counts down word-count effects and takes off any that ran out
+func (s *statusEffects) wordTyped() []*activeEffect {
+	var expired []*activeEffect
+	for _, e := range s.active {
+		if e.duration.Kind != DurationWords {
+			continue
+		}
+		e.wordsLeft--
+		if e.wordsLeft <= 0 {
+			expired = append(expired, e)
+		}
+	}
+	for _, e := range expired {
+		s.remove(e)
+	}
+	return expired
+}
+
+// expireDue takes off timed effects that have run out by now
+func (s *statusEffects) expireDue(now time.Time) []*activeEffect {
+	var expired []*activeEffect
+	for _, e := range s.active {
+		if e.duration.Kind == DurationTimed && !now.Before(e.expires) {
+			expired = append(expired, e)
+		}
+	}
+	for _, e := range expired {
+		s.remove(e)
+	}
+	return expired
+}
+
+// nextExpiry is when the next timed effect runs out
+func (s *statusEffects) nextExpiry() (time.Time, bool) {
+	var next time.Time
+	for _, e := range s.active {
+		if e.duration.Kind == DurationTimed && (next.IsZero() || e.expires.Before(next)) {
+			next = e.expires
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// effectStatus is an effect as the lobby reports it to everyone
+type effectStatus struct {
+	id        PowerupId
+	from      ClientId
+	intensity int
+	kind      DurationKind
+	expires   time.Time
+	wordsLeft int
+}
+
+func (s *statusEffects) status() []effectStatus {
+	status := make([]effectStatus, 0, len(s.active))
+	for _, e := range s.active {
+		status = append(status, effectStatus{
+			id:        e.powerup.Id(),
+			from:      e.from,
+			intensity: e.intensity,
+			kind:      e.duration.Kind,
+			expires:   e.expires,
+			wordsLeft: e.wordsLeft,
+		})
+	}
+	slices.SortFunc(status, func(a, b effectStatus) int { return int(a.id) - int(b.id) })
+	return status
+}
+
+// cooldowns are when the buyer can next use each powerup, kept by their
+// state handler
+type cooldowns [PowerupCount]time.Time
+
+func (cd *cooldowns) ready(p PowerupId, now time.Time) bool {
+	return !now.Before(cd[p])
+}
+
+func (cd *cooldowns) start(p PowerupId, d time.Duration, now time.Time) {
+	cd[p] = now.Add(d)
+}
+
+// clear is for a powerup that never landed
+func (cd *cooldowns) clear(p PowerupId) {
+	cd[p] = time.Time{}
+}
+
+type cooldownStatus struct {
+	id    PowerupId
+	until time.Time
+}
+
+// status is the cooldowns still running at now
+func (cd *cooldowns) status(now time.Time) []cooldownStatus {
+	var status []cooldownStatus
+	for p, until := range cd {
+		if now.Before(until) {
+			status = append(status, cooldownStatus{id: PowerupId(p), until: until})
+		}
+	}
+	return status
+}
+
+// effectTarget is what an effect's hooks get to work with: the racer it
+// landed on, as their state handler sees them
+type effectTarget struct {
+	c       *Client
+	effects *statusEffects
+	// the word they're on
+	idx  int
+	from ClientId
+
+	// words to move them past without typing, which the state handler does
+	// once the hooks have run
+	skip int
+}
+
+func (t *effectTarget) cfg() PowerupConfig {
+	return t.c.cfg.Powerups
+}
+
+// addWords appends added to words, remembering they weren't in the passage
+func (t *effectTarget) addWords(words, added []string) []string {
+	t.c.plain = append(t.c.plain, make([][]string, len(added))...)
+	return append(words, added...)
+}
+
+// mergePlain keeps the plain words in step with MergeRange
+func (t *effectTarget) mergePlain(start, n int) {
+	t.c.plain = mergePairs(t.c.plain, start, n,
+		func(p []string) bool { return len(p) == 1 && isLayout(p[0]) },
+		func(a, b []string) []string { return append(slices.Clip(a), b...) },
+	)
+}
+
+// restoreWords puts the words from the one being typed on back to how they
+// were in the passage, dropping any a powerup added
+func (t *effectTarget) restoreWords() {
+	if t.idx >= len(t.c.words) {
+		return
+	}
+	words := slices.Clone(t.c.words[:t.idx])
+	plain := slices.Clone(t.c.plain[:t.idx])
+	for _, p := range t.c.plain[t.idx:] {
+		for _, w := range p {
+			words = append(words, w)
+			plain = append(plain, []string{w})
+		}
+	}
+	t.c.plain = plain
+	t.rewrite(t.idx, words)
+}
+
+// removeDebuffs takes off everything other racers put on the target
+func (t *effectTarget) removeDebuffs() {
+	for _, e := range t.effects.debuffs() {
+		t.effects.remove(e)
+		if h, ok := e.powerup.(ExpireHook); ok {
+			h.Expire(&effectTarget{c: t.c, effects: t.effects, idx: t.idx, from: e.from})
+		}
+	}
+}
+
+// plainWords is words as they are, one plain word to each. A word a powerup
+// added has none, one it ran together from two has both.
+func plainWords(words []string) [][]string {
+	plain := make([][]string, len(words))
+	for i, w := range words {
+		plain[i] = []string{w}
+	}
+	return plain
+}
+
+// rewrite replaces the target's words and sends them everything from start
+func (t *effectTarget) rewrite(start int, words []string) {
+	t.c.words = words
+	t.c.send(UpdateWordsMessage{
+		idx:   uint32(start),
+		words: words[start:],
+	})
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RaceRecord is a finished race as the store keeps it. Its id matches the
+// race's replay.
+type RaceRecord struct {
+	ID      string `json:"id"`
+	LobbyID int    `json:"lobbyId"`
+	Private bool   `json:"private"`
+	Words   string `json:"words,omitempty"`
+	// seconds, for time attack races
+	TimeAttack int         `json:"timeAttack,omitempty"`
+	StartedAt  time.Time   `json:"startedAt"`
+	Results    []RaceEntry `json:"results"`
+}
+
+type RaceEntry struct {
+	Name            string   `json:"name"`
+	Placement       int      `json:"placement"`
+	Status          string   `json:"status"`
+	WPM             int      `json:"wpm"`
+	RawWPM          int      `json:"rawWpm"`
+	WordsTyped      int      `json:"wordsTyped"`
+	Team            int      `json:"team,omitempty"`
+	Bot             bool     `json:"bot,omitempty"`
+	Accuracy        float32  `json:"accuracy"`
+	TimeTakenMs     int64    `json:"timeTakenMs"`
+	PowerupsUsed    []string `json:"powerupsUsed"`
+	EffectsReceived []string `json:"effectsReceived"`
+}
+
+func (e RaceEntry) finished() bool {
+	return e.Status == ResultFinished.String()
+}
+
+// PlayerRace is one race from a single player's point of view
+type PlayerRace struct {
+	RaceID    string    `json:"raceId"`
+	StartedAt time.Time `json:"startedAt"`
+	Players   int       `json:"players"`
+	RaceEntry
+}
+
+type Profile struct {
+	Name      string    `json:"name"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+
+	Races    int `json:"races"`
+	Finished int `json:"finished"`
+	Wins     int `json:"wins"`
+
+	PowerupsUsed    map[string]int `json:"powerupsUsed"`
+	EffectsReceived map[string]int `json:"effectsReceived"`
+
+	Bests PersonalBests `json:"bests"`
+}
+
+// PersonalBests only count races the player finished
+type PersonalBests struct {
+	WPM           int     `json:"wpm"`
+	Accuracy      float32 `json:"accuracy"`
+	FastestTimeMs int64   `json:"fastestTimeMs"`
+}
+
+// Store keeps players' race history across restarts. It's called from lobby
+// goroutines and HTTP handlers alike, so implementations must be safe for
+// concurrent use.
+type Store interface {
+	SaveRace(race RaceRecord) error
+	Profile(name string) (Profile, bool)
+	// History is newest first, limit <= 0 means everything
+	History(name string, limit int) []PlayerRace
+	// Races is every stored race, oldest first
+	Races() []RaceRecord
+}
+
+const raceLogFile = "races.jsonl"
+
+// fileStore appends every race to a JSON lines file and rebuilds profiles
+// from it on startup. With no directory it only keeps races in memory.
+type fileStore struct {
+	mu sync.Mutex
+
+	path string
+
+	races    []RaceRecord
+	profiles map[string]*Profile
+	// indices into races, per player key
+	byPlayer map[string][]int
+}
+
+func OpenStore(dir string) (Store, error) {
+	s := &fileStore{
+		profiles: make(map[string]*Profile),
+		byPlayer: make(map[string][]int),
+	}
+
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	s.path = filepath.Join(dir, raceLogFile)
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var race RaceRecord
+		if err := json.Unmarshal(scanner.Bytes(), &race); err != nil {
+			return nil, fmt.Errorf("store: %s line %d: %w", s.path, line, err)
+		}
+		s.add(race)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+
+	return s, nil
+}
+
+func playerKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func (s *fileStore) SaveRace(race RaceRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path != "" {
+		line, err := json.Marshal(race)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+
+	s.add(race)
+	return nil
+}
+
+// add folds a race into the in-memory indexes, callers hold mu
+func (s *fileStore) add(race RaceRecord) {
+	idx := len(s.races)
+	s.races = append(s.races, race)
+
+	for _, e := range race.Results {
+		key := playerKey(e.Name)
+		// bots don't get profiles
+		if key == "" || e.Bot {
+			continue
+		}
+
+		s.byPlayer[key] = append(s.byPlayer[key], idx)
+
+		p, ok := s.profiles[key]
+		if !ok {
+			p = &Profile{
+				Name:            e.Name,
+				FirstSeen:       race.StartedAt,
+				PowerupsUsed:    make(map[string]int),
+				EffectsReceived: make(map[string]int),
+			}
+			s.profiles[key] = p
+		}
+
+		p.Name = e.Name
+		p.LastSeen = race.StartedAt
+		p.Races++
+
+		for _, pid := range e.PowerupsUsed {
+			p.PowerupsUsed[pid]++
+		}
+		for _, pid := range e.EffectsReceived {
+			p.EffectsReceived[pid]++
+		}
+
+		if !e.finished() {
+			continue
+		}
+
+		p.Finished++
+		if e.Placement == 1 {
+			p.Wins++
+		}
+
+		p.Bests.WPM = max(p.Bests.WPM, e.WPM)
+		p.Bests.Accuracy = max(p.Bests.Accuracy, e.Accuracy)
+		if p.Bests.FastestTimeMs == 0 || e.TimeTakenMs < p.Bests.FastestTimeMs {
+			p.Bests.FastestTimeMs = e.TimeTakenMs
+		}
+	}
+}
+
+func (s *fileStore) Profile(name string) (Profile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.profiles[playerKey(name)]
+	if !ok {
+		return Profile{}, false
+	}
+	// add keeps counting into the maps after the lock is let go
+	profile := *p
+	profile.PowerupsUsed = maps.Clone(p.PowerupsUsed)
+	profile.EffectsReceived = maps.Clone(p.EffectsReceived)
+	return profile, true
+}
+
+func (s *fileStore) Races() []RaceRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.races)
+}
+
+func (s *fileStore) History(name string, limit int) []PlayerRace {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := playerKey(name)
+	idxs := s.byPlayer[key]
+
+	history := make([]PlayerRace, 0, len(idxs))
+	for i := len(idxs) - 1; i >= 0; i-- {
+		if limit > 0 && len(history) == limit {
+			break
+		}
+
+		race := s.races[idxs[i]]
+		for _, e := range race.Results {
+			if playerKey(e.Name) == key {
+				history = append(history, PlayerRace{
+					RaceID:    race.ID,
+					StartedAt: race.StartedAt,
+					Players:   len(race.Results),
+					RaceEntry: e,
+				})
+				break
+			}
+		}
+	}
+
+	return history
+}
+
+// ---- HTTP ----
+
+const defaultHistoryLimit = 20
+
+func serveProfile(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		profile, ok := store.Profile(r.PathValue("name"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profile)
+	}
+}
+
+// serveHistory takes ?limit=N, 0 for every race
+func serveHistory(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if _, ok := store.Profile(name); !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		limit := defaultHistoryLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "limit must be a non-negative number", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(store.History(name, limit))
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ---- Teams ----
+//
+// A team race splits the lobby into teams when the race starts, dealing
+// players out by rating so the teams come out even. A team's progress is the
+// average of its members', powerups only land on the other teams, and teams
+// are placed by average points, where a player earns one point for every
+// racer they placed ahead of, plus one.
+
+const maxTeams = 4
+
+type TeamId = byte
+
+func validateTeams(teams byte, cfg *Config) error {
+	if teams == 0 {
+		return nil
+	}
+	if teams < 2 || teams > maxTeams || int(teams) > cfg.ClientsPerLobby {
+		return fmt.Errorf("teams must be between 2 and %d, got %d",
+			min(maxTeams, cfg.ClientsPerLobby), teams)
+	}
+	return nil
+}
+
+// assignTeams deals the racers out best first, snaking back and forth so no
+// team gets all the top picks. With fewer racers than teams it's an ordinary
+// race.
+func (l *Lobby) assignTeams() {
+	teams := min(int(l.teams), len(l.results))
+	if teams < 2 {
+		if l.teams > 0 {
+			l.log("not enough players for %d teams, racing solo", l.teams)
+		}
+		l.teams = 0
+		return
+	}
+	l.teams = byte(teams)
+
+	rs := make([]*playerResult, 0, len(l.results))
+	for _, r := range l.results {
+		rs = append(rs, r)
+	}
+	ratings := make(map[ClientId]float64, len(rs))
+	for _, r := range rs {
+		ratings[r.id] = l.hub.ratings.Get(r.name)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		if ratings[rs[i].id] != ratings[rs[j].id] {
+			return ratings[rs[i].id] > ratings[rs[j].id]
+		}
+		return rs[i].id < rs[j].id
+	})
+
+	for i, r := range rs {
+		round, pick := i/teams, i%teams
+		if round%2 == 1 {
+			pick = teams - 1 - pick
+		}
+		r.team = TeamId(pick)
+	}
+}
+
+func (l *Lobby) teamAssignment() TeamAssignmentMessage {
+	msg := TeamAssignmentMessage{Teams: l.teams}
+	for _, r := range l.results {
+		msg.Members = append(msg.Members, TeamMember{PlayerID: r.id, Team: r.team})
+	}
+	return msg
+}
+
+// sameTeam is false outside team races, where everyone is an opponent
+func (l *Lobby) sameTeam(a, b ClientId) bool {
+	if l.teams == 0 {
+		return false
+	}
+	ra, okA := l.results[a]
+	rb, okB := l.results[b]
+	return okA && okB && ra.team == rb.team
+}
+
+func (l *Lobby) teamProgress(team TeamId) TeamProgressMessage {
+	var progress float32
+	var wpm uint32
+	members := 0
+	for _, r := range l.results {
+		if r.team != team {
+			continue
+		}
+		progress += r.progress
+		wpm += r.wpm
+		members++
+	}
+
+	return TeamProgressMessage{
+		Team:     team,
+		Progress: progress / float32(max(members, 1)),
+		WPM:      wpm / uint32(max(members, 1)),
+	}
+}
+
+// teamResults places the teams from the final standings
+func (l *Lobby) teamResults(standings []*playerResult) TeamResultsMessage {
+	results := make([]TeamResult, l.teams)
+	points := make([]int, l.teams)
+	members := make([]int, l.teams)
+	for t := range results {
+		results[t].Team = TeamId(t)
+		results[t].BestPlacement = 255
+	}
+
+	for _, r := range standings {
+		t := &results[r.team]
+		points[r.team] += len(standings) - int(r.placement) + 1
+		members[r.team]++
+		t.Progress += r.progress
+		t.WPM += r.wpm
+		t.BestPlacement = min(t.BestPlacement, r.placement)
+		if r.finished {
+			t.Finished++
+		}
+	}
+
+	for t := range results {
+		n := max(members[t], 1)
+		results[t].Points = float32(points[t]) / float32(n)
+		results[t].Progress /= float32(n)
+		results[t].WPM /= uint32(n)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		return a.BestPlacement < b.BestPlacement
+	})
+	for i := range results {
+		results[i].Placement = byte(i + 1)
+	}
+
+	return TeamResultsMessage{Results: results}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ---- Time attack ----
+//
+// A time attack race has no finish line. Players type an endless stream of
+// words until the clock runs out and are ranked by how many they got through.
+// Progress is how much of the clock has gone rather than how much of the
+// words.
+
+var timeAttackDurations = []uint16{15, 30, 60, 120}
+
+// more words are sent once a player is within timeAttackLookahead of the end
+const timeAttackLookahead = 20
+const timeAttackBatch = 50
+
+func validateTimeAttack(secs uint16, source WordSource) error {
+	if secs == 0 {
+		return nil
+	}
+	if !slices.Contains(timeAttackDurations, secs) {
+		return fmt.Errorf("time attack must last one of %v seconds, got %d", timeAttackDurations, secs)
+	}
+	if _, ok := source.(Passage); ok {
+		return fmt.Errorf("%s can't be raced against the clock", source.Name())
+	}
+	return nil
+}
+
+// timeProgress is the share of a time attack race that has gone, from 0 to 1
+func timeProgress(elapsed time.Duration, secs uint16) float32 {
+	return min(float32(elapsed)/float32(time.Duration(secs)*time.Second), 1)
+}
+
+// tickTimeAttack moves everyone still racing along with the clock, so the
+// bars of players who stopped typing don't stall
+func (l *Lobby) tickTimeAttack() {
+	progress := timeProgress(time.Since(l.raceStart), l.timeAttack)
+	for _, r := range l.results {
+		if r.finished || r.disconnected {
+			continue
+		}
+		r.progress = progress
+		l.broadcast(ProgressUpdateV2Message{
+			PlayerID: r.id,
+			Progress: r.progress,
+			WPM:      r.wpm,
+			RawWPM:   r.rawWpm,
+			Accuracy: r.accuracy,
+		})
+	}
+	for t := range l.teams {
+		l.broadcast(l.teamProgress(t))
+	}
+}
+
+// streamWords tops up a time attack player's words once idx gets close to
+// the end
+func (c *Client) streamWords(idx int) {
+	if len(c.words)-idx > timeAttackLookahead {
+		return
+	}
+
+	start := len(c.words)
+	c.words = append(c.words, c.wordSource.Words(timeAttackBatch)...)
+	c.plain = append(c.plain, plainWords(c.words[start:])...)
+	c.send(UpdateWordsMessage{
+		idx:   uint32(start),
+		words: c.words[start:],
+	})
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// ---- Tournaments ----
+//
+// A tournament takes a roster and races it down to a champion in rounds of
+// heats. Every heat is a private lobby that only its seeded players can join
+// by code. When the last heat of a round finishes, the top finishers of each
+// heat are seeded into the next round, until a round fits in one heat: its
+// winner takes the tournament.
+//
+// Tournaments live in memory. They're shared between HTTP handlers, the hub
+// and heat lobbies, so like the leaderboards they're guarded by a mutex.
+
+type TournamentStatus string
+
+const (
+	TournamentRunning  TournamentStatus = "running"
+	TournamentFinished TournamentStatus = "finished"
+)
+
+type HeatStatus string
+
+const (
+	// seeded, waiting on the hub for a lobby
+	HeatPending HeatStatus = "pending"
+	// lobby open for the heat's players
+	HeatOpen     HeatStatus = "open"
+	HeatFinished HeatStatus = "finished"
+)
+
+// how many feed events a slow watcher can fall behind before missing some
+const tournamentFeedBuffer = 16
+
+type Tournament struct {
+	ID     int              `json:"id"`
+	Name   string           `json:"name"`
+	Status TournamentStatus `json:"status"`
+	Roster []string         `json:"roster"`
+	// players per heat, and how many of each heat move on
+	HeatSize int       `json:"heatSize"`
+	Advance  int       `json:"advance"`
+	Source   string    `json:"source,omitempty"`
+	Language string    `json:"language,omitempty"`
+	Rounds   [][]*Heat `json:"rounds"`
+	Champion string    `json:"champion,omitempty"`
+
+	feeds map[chan []byte]struct{}
+}
+
+type Heat struct {
+	Round int `json:"round"`
+	Index int `json:"heat"`
+	// seeded best first, set before the heat is handed to the hub and never
+	// changed after
+	Players  []string     `json:"players"`
+	Status   HeatStatus   `json:"status"`
+	Code     string       `json:"code,omitempty"`
+	LobbyID  int          `json:"lobbyId"`
+	RaceID   string       `json:"raceId,omitempty"`
+	Results  []HeatResult `json:"results,omitempty"`
+	Advanced []string     `json:"advanced,omitempty"`
+
+	tournament *Tournament
+}
+
+// HeatResult is how a seeded player did, players who never showed up last
+type HeatResult struct {
+	Name      string `json:"name"`
+	Placement int    `json:"placement"`
+	Status    string `json:"status"`
+	WPM       int    `json:"wpm"`
+}
+
+// TournamentEvent goes out on a tournament's feed whenever its bracket
+// changes, with the whole bracket as it stands
+type TournamentEvent struct {
+	Type       string      `json:"type"`
+	Round      int         `json:"round"`
+	Heat       int         `json:"heat"`
+	Tournament *Tournament `json:"tournament"`
+}
+
+const (
+	EventBracket      = "bracket"
+	EventHeatOpened   = "heatOpened"
+	EventHeatFinished = "heatFinished"
+	EventRoundStarted = "roundStarted"
+	EventFinished     = "finished"
+)
+
+type Tournaments struct {
+	mu     sync.Mutex
+	hub    *Hub
+	byId   map[int]*Tournament
+	nextId int
+}
+
+func newTournaments(hub *Hub) *Tournaments {
+	return &Tournaments{
+		hub:  hub,
+		byId: make(map[int]*Tournament),
+	}
+}
+
+// admits is whether name was seeded into the heat
+func (heat *Heat) admits(name string) bool {
+	return slices.ContainsFunc(heat.Players, func(p string) bool {
+		return playerKey(p) == playerKey(name)
+	})
+}
+
+// seedHeats splits players, best first, into as few heats of at most
+// heatSize as will hold them, snaking so every heat gets a fair share of the
+// top seeds
+func seedHeats(players []string, heatSize int) [][]string {
+	heats := make([][]string, (len(players)+heatSize-1)/heatSize)
+	for i, p := range players {
+		round, pick := i/len(heats), i%len(heats)
+		if round%2 == 1 {
+			pick = len(heats) - 1 - pick
+		}
+		heats[pick] = append(heats[pick], p)
+	}
+	return heats
+}
+
+// advancing is how many of a heat move on. Heats always knock someone out
+// so every round is smaller than the last, and the final has one winner.
+func (t *Tournament) advancing(heat *Heat) int {
+	if len(t.Rounds[heat.Round]) == 1 {
+		return 1
+	}
+	return min(t.Advance, max(len(heat.Players)-1, 1))
+}
+
+// startRound seeds a round and returns its heats for the hub. Must hold the
+// lock.
+func (t *Tournament) startRound(players []string) []*Heat {
+	round := len(t.Rounds)
+	var heats []*Heat
+	for i, ps := range seedHeats(players, t.HeatSize) {
+		heats = append(heats, &Heat{
+			Round:      round,
+			Index:      i,
+			Players:    ps,
+			Status:     HeatPending,
+			tournament: t,
+		})
+	}
+	t.Rounds = append(t.Rounds, heats)
+	t.publish(TournamentEvent{Type: EventRoundStarted, Round: round})
+	return heats
+}
+
+// publish sends an event to the tournament's feeds. Must hold the lock.
+func (t *Tournament) publish(e TournamentEvent) {
+	e.Tournament = t
+	// encoded here, the bracket keeps changing once the lock is released
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("tournament %d: encoding event: %s", t.ID, err)
+		return
+	}
+
+	for feed := range t.feeds {
+		select {
+		case feed <- data:
+		default:
+			log.Printf("tournament %d: feed fell behind, dropped %s", t.ID, e.Type)
+		}
+	}
+}
+
+func (ts *Tournaments) create(t *Tournament) {
+	ts.mu.Lock()
+	t.ID = ts.nextId
+	ts.nextId++
+	t.Status = TournamentRunning
+	t.feeds = make(map[chan []byte]struct{})
+	ts.byId[t.ID] = t
+
+	players := slices.Clone(t.Roster)
+	sort.SliceStable(players, func(i, j int) bool {
+		return ts.hub.ratings.Get(players[i]) > ts.hub.ratings.Get(players[j])
+	})
+	heats := t.startRound(players)
+	ts.mu.Unlock()
+
+	log.Printf("tournament %d: %q started with %d players", t.ID, t.Name, len(t.Roster))
+	// the hub can be busy for a while, don't hold up the request on it
+	go ts.openHeats(heats)
+}
+
+// openHeats asks the hub for the heats' lobbies
+func (ts *Tournaments) openHeats(heats []*Heat) {
+	for _, heat := range heats {
+		ts.hub.heatQueue <- heat
+	}
+}
+
+// heatOpened is called by the hub once a heat's lobby is running
+func (ts *Tournaments) heatOpened(heat *Heat, l *Lobby) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	heat.Status = HeatOpen
+	heat.Code = l.code
+	heat.LobbyID = l.id
+	heat.tournament.publish(TournamentEvent{Type: EventHeatOpened, Round: heat.Round, Heat: heat.Index})
+}
+
+// heatFinished is called by a heat's lobby with its race, and starts the next
+// round once the rest of the round is done
+func (ts *Tournaments) heatFinished(heat *Heat, race RaceRecord) {
+	ts.mu.Lock()
+	t := heat.tournament
+
+	heat.Status = HeatFinished
+	heat.RaceID = race.ID
+	raced := make(map[string]bool)
+	for _, r := range race.Results {
+		heat.Results = append(heat.Results, HeatResult{
+			Name:      r.Name,
+			Placement: r.Placement,
+			Status:    r.Status,
+			WPM:       r.WPM,
+		})
+		raced[playerKey(r.Name)] = true
+	}
+	for _, p := range heat.Players {
+		if !raced[playerKey(p)] {
+			heat.Results = append(heat.Results, HeatResult{
+				Name:      p,
+				Placement: len(heat.Results) + 1,
+				Status:    "absent",
+			})
+		}
+	}
+
+	// only players who finished move on, results are best first
+	for _, r := range race.Results {
+		if len(heat.Advanced) == t.advancing(heat) {
+			break
+		}
+		if r.finished() {
+			heat.Advanced = append(heat.Advanced, r.Name)
+		}
+	}
+	t.publish(TournamentEvent{Type: EventHeatFinished, Round: heat.Round, Heat: heat.Index})
+
+	round := t.Rounds[heat.Round]
+	for _, h := range round {
+		if h.Status != HeatFinished {
+			ts.mu.Unlock()
+			return
+		}
+	}
+
+	// heat winners seed first, then the runners up, and so on
+	var players []string
+	for place := 0; ; place++ {
+		added := false
+		for _, h := range round {
+			if place < len(h.Advanced) {
+				players = append(players, h.Advanced[place])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	var next []*Heat
+	if len(players) > 1 {
+		next = t.startRound(players)
+	} else {
+		t.Status = TournamentFinished
+		if len(players) == 1 {
+			t.Champion = players[0]
+		}
+		log.Printf("tournament %d: finished, champion %q", t.ID, t.Champion)
+		t.publish(TournamentEvent{Type: EventFinished, Round: heat.Round})
+		for feed := range t.feeds {
+			close(feed)
+		}
+		t.feeds = nil
+	}
+	ts.mu.Unlock()
+
+	// the hub may be blocked on this lobby
+	go ts.openHeats(next)
+}
+
+func (ts *Tournaments) get(id int) (*Tournament, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t, ok := ts.byId[id]
+	return t, ok
+}
+
+// ---- HTTP ----
+
+type createTournamentRequest struct {
+	Name     string   `json:"name"`
+	Roster   []string `json:"roster"`
+	HeatSize int      `json:"heatSize"`
+	Advance  int      `json:"advance"`
+	Source   string   `json:"source"`
+	Language string   `json:"language"`
+}
+
+func (ts *Tournaments) validate(req createTournamentRequest) error {
+	cfg := ts.hub.cfg
+	if req.HeatSize < 2 || req.HeatSize > cfg.ClientsPerLobby {
+		return fmt.Errorf("heatSize must be between 2 and %d", cfg.ClientsPerLobby)
+	}
+	if req.Advance < 1 || req.Advance >= req.HeatSize {
+		return fmt.Errorf("advance must be between 1 and %d", req.HeatSize-1)
+	}
+	if len(req.Roster) < 2 {
+		return fmt.Errorf("a tournament needs at least 2 players")
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range req.Roster {
+		key := playerKey(name)
+		if key == "" || len(name) > 255 {
+			return fmt.Errorf("player names must be 1 to 255 bytes")
+		}
+		if seen[key] {
+			return fmt.Errorf("%q is on the roster twice", name)
+		}
+		seen[key] = true
+	}
+
+	if _, err := ts.hub.words.resolve(req.Source, req.Language); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ServeCreate starts a tournament from a JSON roster and answers with its
+// bracket
+func (ts *Tournaments) ServeCreate(w http.ResponseWriter, r *http.Request) {
+	var req createTournamentRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "invalid tournament: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := ts.validate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t := &Tournament{
+		Name:     req.Name,
+		Roster:   req.Roster,
+		HeatSize: req.HeatSize,
+		Advance:  req.Advance,
+		Source:   req.Source,
+		Language: req.Language,
+	}
+	ts.create(t)
+
+	ts.writeJSON(w, http.StatusCreated, t)
+}
+
+func (ts *Tournaments) ServeList(w http.ResponseWriter, r *http.Request) {
+	ts.mu.Lock()
+	list := make([]*Tournament, 0, len(ts.byId))
+	for _, t := range ts.byId {
+		list = append(list, t)
+	}
+	ts.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	ts.writeJSON(w, http.StatusOK, list)
+}
+
+func (ts *Tournaments) ServeBracket(w http.ResponseWriter, r *http.Request) {
+	t, ok := ts.lookup(w, r)
+	if !ok {
+		return
+	}
+	ts.writeJSON(w, http.StatusOK, t)
+}
+
+// ServeFeed sends the bracket over a websocket, then an event every time it
+// changes, closing once the tournament is over
+func (ts *Tournaments) ServeFeed(w http.ResponseWriter, r *http.Request) {
+	t, ok := ts.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("upgrading error: %s\n", err)
+		return
+	}
+	defer conn.Close()
+
+	ts.mu.Lock()
+	first, _ := json.Marshal(TournamentEvent{Type: EventBracket, Tournament: t})
+	feed := make(chan []byte, tournamentFeedBuffer)
+	if t.feeds != nil {
+		t.feeds[feed] = struct{}{}
+	} else {
+		close(feed)
+	}
+	ts.mu.Unlock()
+
+	// watchers only listen, reading is just to notice them leaving
+	left := make(chan struct{})
+	go func() {
+		defer close(left)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	defer func() {
+		ts.mu.Lock()
+		delete(t.feeds, feed)
+		ts.mu.Unlock()
+	}()
+
+	if err := conn.WriteMessage(websocket.TextMessage, first); err != nil {
+		return
+	}
+	for {
+		select {
+		case data, ok := <-feed:
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-left:
+			return
+		}
+	}
+}
+
+func (ts *Tournaments) lookup(w http.ResponseWriter, r *http.Request) (*Tournament, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid tournament id", http.StatusBadRequest)
+		return nil, false
+	}
+	t, ok := ts.get(id)
+	if !ok {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return t, true
+}
+
+// writeJSON encodes under the lock, heats finish while handlers read
+func (ts *Tournaments) writeJSON(w http.ResponseWriter, status int, v any) {
+	ts.mu.Lock()
+	data, err := json.Marshal(v)
+	ts.mu.Unlock()
+	if err != nil {
+		log.Printf("encoding tournaments: %s", err)
+		http.Error(w, "could not encode tournament", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
@@ -2,212 +2,11 @@ package main
 
 import (
 	"math/rand"
+	"slices"
 	"strings"
+	"unicode"
 )
 
-var wordsEnglish = []string{
-	"the",
-	"be",
-	"of",
-	"and",
-	"a",
-	"to",
-	"in",
-	"he",
-	"have",
-	"it",
-	"that",
-	"for",
-	"they",
-	"I",
-	"with",
-	"as",
-	"not",
-	"on",
-	"she",
-	"at",
-	"by",
-	"this",
-	"we",
-	"you",
-	"do",
-	"but",
-	"from",
-	"or",
-	"which",
-	"one",
-	"would",
-	"all",
-	"will",
-	"there",
-	"say",
-	"who",
-	"make",
-	"when",
-	"can",
-	"more",
-	"if",
-	"no",
-	"man",
-	"out",
-	"other",
-	"so",
-	"what",
-	"time",
-	"up",
-	"go",
-	"about",
-	"than",
-	"into",
-	"could",
-	"state",
-	"only",
-	"new",
-	"year",
-	"some",
-	"take",
-	"come",
-	"these",
-	"know",
-	"see",
-	"use",
-	"get",
-	"like",
-	"then",
-	"first",
-	"any",
-	"work",
-	"now",
-	"may",
-	"such",
-	"give",
-	"over",
-	"think",
-	"most",
-	"even",
-	"find",
-	"day",
-	"also",
-	"after",
-	"way",
-	"many",
-	"must",
-	"look",
-	"before",
-	"great",
-	"back",
-	"through",
-	"long",
-	"where",
-	"much",
-	"should",
-	"well",
-	"people",
-	"down",
-	"own",
-	"just",
-	"because",
-	"good",
-	"each",
-	"those",
-	"feel",
-	"seem",
-	"how",
-	"high",
-	"too",
-	"place",
-	"little",
-	"world",
-	"very",
-	"still",
-	"nation",
-	"hand",
-	"old",
-	"life",
-	"tell",
-	"write",
-	"become",
-	"here",
-	"show",
-	"house",
-	"both",
-	"between",
-	"need",
-	"mean",
-	"call",
-	"develop",
-	"under",
-	"last",
-	"right",
-	"move",
-	"thing",
-	"general",
-	"school",
-	"never",
-	"same",
-	"another",
-	"begin",
-	"while",
-	"number",
-	"part",
-	"turn",
-	"real",
-	"leave",
-	"might",
-	"want",
-	"point",
-	"form",
-	"off",
-	"child",
-	"few",
-	"small",
-	"since",
-	"against",
-	"ask",
-	"late",
-	"home",
-	"interest",
-	"large",
-	"person",
-	"end",
-	"open",
-	"public",
-	"follow",
-	"during",
-	"present",
-	"without",
-	"again",
-	"hold",
-	"govern",
-	"around",
-	"possible",
-	"head",
-	"consider",
-	"word",
-	"program",
-	"problem",
-	"however",
-	"lead",
-	"system",
-	"set",
-	"order",
-	"eye",
-	"plan",
-	"run",
-	"keep",
-	"face",
-	"fact",
-	"group",
-	"play",
-	"stand",
-	"increase",
-	"early",
-	"course",
-	"change",
-	"help",
-	"line",
-}
-
 func RandomWords(words []string, n int) []string {
 	out := make([]string, n)
 	for i := 0; i < n; i++ {
@@ -217,6 +16,57 @@ func RandomWords(words []string, n int) []string {
 
 }
 
+// ---- Syntax ----
+//
+// Quote and code races mix punctuation and symbols into words, and code races
+// type line breaks and indentation as units of their own. The transforms
+// below leave layout units alone and only rework the letters and digits of a
+// word, so neither indentation nor syntax gets mangled.
+
+// isLayout reports whether w is a line break with indentation rather than
+// something to be typed as text
+func isLayout(w string) bool {
+	return w != "" && strings.TrimLeft(w, " \t\n") == ""
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func isPlainWord(w string) bool {
+	for _, r := range w {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// mapWordRuns replaces each run of letters, digits and underscores in w with
+// f of it, keeping everything between the runs as it was
+func mapWordRuns(w string, f func(string) string) string {
+	var b strings.Builder
+	start := -1
+
+	for i, r := range w {
+		switch {
+		case isWordRune(r) && start < 0:
+			start = i
+		case !isWordRune(r) && start >= 0:
+			b.WriteString(f(w[start:i]))
+			start = -1
+			fallthrough
+		case !isWordRune(r):
+			b.WriteRune(r)
+		}
+	}
+	if start >= 0 {
+		b.WriteString(f(w[start:]))
+	}
+
+	return b.String()
+}
+
 // ---- Obfuscation ----
 
 func ObfuscateRange(words []string, offset, n int) []string {
@@ -229,8 +79,18 @@ func ObfuscateRange(words []string, offset, n int) []string {
 		end = len(words)
 	}
 
+	// extra symbols would read as syntax in code or punctuation in prose, so
+	// those only get letters swapped
+	code := slices.ContainsFunc(words, isLayout)
+
 	for i := offset; i < end; i++ {
-		words[i] = obfuscateWord(words[i])
+		switch {
+		case isLayout(words[i]):
+		case isPlainWord(words[i]) && !code:
+			words[i] = obfuscateWord(words[i])
+		default:
+			words[i] = mapWordRuns(words[i], leet)
+		}
 	}
 
 	return words
@@ -253,12 +113,28 @@ var leetMap = map[rune][]rune{
 
 var punctuation = []rune{'!', ',', '.', ':', ';'}
 
+func leetRune(r rune) (rune, bool) {
+	if repls, ok := leetMap[r]; ok && rand.Float64() < 0.3 {
+		return repls[rand.Intn(len(repls))], true
+	}
+	return r, false
+}
+
+func leet(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		r, _ = leetRune(r)
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 func obfuscateWord(s string) string {
 	var b strings.Builder
 
 	for _, r := range s {
-		if repls, ok := leetMap[r]; ok && rand.Float64() < 0.3 {
-			b.WriteRune(repls[rand.Intn(len(repls))])
+		if repl, ok := leetRune(r); ok {
+			b.WriteRune(repl)
 			continue
 		}
 
@@ -289,16 +165,23 @@ func ScrambleRange(words []string, offset, n int) []string {
 	}
 
 	for i := offset; i < end; i++ {
-		r := []rune(words[i])
-		rand.Shuffle(len(r), func(a, b int) {
-			r[a], r[b] = r[b], r[a]
-		})
-		words[i] = string(r)
+		if isLayout(words[i]) {
+			continue
+		}
+		words[i] = mapWordRuns(words[i], scramble)
 	}
 
 	return words
 }
 
+func scramble(s string) string {
+	r := []rune(s)
+	rand.Shuffle(len(r), func(a, b int) {
+		r[a], r[b] = r[b], r[a]
+	})
+	return string(r)
+}
+
 func RepeatCharsRange(words []string, offset, n int) []string {
 	if offset < 0 || offset >= len(words) || n <= 0 {
 		return words
@@ -307,7 +190,10 @@ func RepeatCharsRange(words []string, offset, n int) []string {
 	end := min(offset + n, len(words))
 
 	for i := offset; i < end; i++ {
-		words[i] = repeatChars(words[i])
+		if isLayout(words[i]) {
+			continue
+		}
+		words[i] = mapWordRuns(words[i], repeatChars)
 	}
 
 	return words
@@ -317,7 +203,7 @@ func repeatChars(s string) string {
 	var b strings.Builder
 
 	for _, r := range s {
-		repeat := rand.Intn(3) + 1 // 1..5
+		repeat := rand.Intn(3) + 1 // 1..3
 		for range repeat {
 			b.WriteRune(r)
 		}
@@ -333,3 +219,212 @@ func countCharsWithSpaces(words []string, n int) int {
 	}
 	return res + len(words[n - 1])
 }
+
+// ---- Reversing ----
+
+func ReverseRange(words []string, offset, n int) []string {
+	if offset < 0 || offset >= len(words) || n <= 0 {
+		return words
+	}
+
+	end := min(offset+n, len(words))
+
+	for i := offset; i < end; i++ {
+		if isLayout(words[i]) {
+			continue
+		}
+		words[i] = mapWordRuns(words[i], reverse)
+	}
+
+	return words
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	slices.Reverse(r)
+	return string(r)
+}
+
+// ---- Vowel dropping ----
+
+func DropVowelsRange(words []string, offset, n int) []string {
+	if offset < 0 || offset >= len(words) || n <= 0 {
+		return words
+	}
+
+	end := min(offset+n, len(words))
+
+	for i := offset; i < end; i++ {
+		if isLayout(words[i]) {
+			continue
+		}
+		words[i] = mapWordRuns(words[i], dropVowels)
+	}
+
+	return words
+}
+
+func isVowel(r rune) bool {
+	return strings.ContainsRune("aeiouAEIOU", r)
+}
+
+// dropVowels keeps the first letter so there's always something to type
+func dropVowels(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && isVowel(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ---- Homoglyphs ----
+//
+// Swaps letters for Cyrillic ones that look the same. Nobody can type those,
+// so a word is still typed as the plain letters it shows; see plainText.
+
+var homoglyphs = map[rune]rune{
+	'a': '\u0430',
+	'c': '\u0441',
+	'd': '\u0501',
+	'e': '\u0435',
+	'h': '\u04bb',
+	'i': '\u0456',
+	'j': '\u0458',
+	'o': '\u043e',
+	'p': '\u0440',
+	's': '\u0455',
+	'x': '\u0445',
+	'y': '\u0443',
+	'A': '\u0410',
+	'B': '\u0412',
+	'C': '\u0421',
+	'E': '\u0415',
+	'H': '\u041d',
+	'I': '\u0406',
+	'J': '\u0408',
+	'K': '\u041a',
+	'M': '\u041c',
+	'O': '\u041e',
+	'P': '\u0420',
+	'S': '\u0405',
+	'T': '\u0422',
+	'X': '\u0425',
+}
+
+// plainHomoglyphs undoes homoglyphs
+var plainHomoglyphs = func() map[rune]rune {
+	plain := make(map[rune]rune, len(homoglyphs))
+	for r, h := range homoglyphs {
+		plain[h] = r
+	}
+	return plain
+}()
+
+func HomoglyphRange(words []string, offset, n int) []string {
+	if offset < 0 || offset >= len(words) || n <= 0 {
+		return words
+	}
+
+	end := min(offset+n, len(words))
+
+	for i := offset; i < end; i++ {
+		if isLayout(words[i]) {
+			continue
+		}
+		words[i] = mapWordRuns(words[i], homoglyph)
+	}
+
+	return words
+}
+
+func homoglyph(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if repl, ok := homoglyphs[r]; ok && rand.Float64() < 0.5 {
+			r = repl
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// plainText is w with any homoglyphs swapped back, which is what typing w
+// takes
+func plainText(w string) string {
+	return strings.Map(func(r rune) rune {
+		if plain, ok := plainHomoglyphs[r]; ok {
+			return plain
+		}
+		return r
+	}, w)
+}
+
+// ---- Random caps ----
+
+func RandomCapsRange(words []string, offset, n int) []string {
+	if offset < 0 || offset >= len(words) || n <= 0 {
+		return words
+	}
+
+	end := min(offset+n, len(words))
+
+	for i := offset; i < end; i++ {
+		if isLayout(words[i]) {
+			continue
+		}
+		words[i] = mapWordRuns(words[i], randomCaps)
+	}
+
+	return words
+}
+
+func randomCaps(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if rand.Float64() < 0.5 {
+			if unicode.IsUpper(r) {
+				r = unicode.ToLower(r)
+			} else {
+				r = unicode.ToUpper(r)
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ---- Merging ----
+
+// MergeRange runs the n words from offset together in pairs, so there are
+// fewer words afterwards. Layout units are never merged, they'd stop reading
+// as layout.
+func MergeRange(words []string, offset, n int) []string {
+	return mergePairs(words, offset, n, isLayout, func(a, b string) string {
+		return a + b
+	})
+}
+
+// mergePairs joins items from offset in pairs the way MergeRange does words,
+// so anything kept alongside the words can be merged to match
+func mergePairs[T any](items []T, offset, n int, layout func(T) bool, join func(a, b T) T) []T {
+	if offset < 0 || offset >= len(items) || n <= 0 {
+		return items
+	}
+
+	end := min(offset+n, len(items))
+
+	merged := slices.Clone(items[:offset])
+	for i := offset; i < end; i++ {
+		if i+1 < end && !layout(items[i]) && !layout(items[i+1]) {
+			merged = append(merged, join(items[i], items[i+1]))
+			i++
+			continue
+		}
+		merged = append(merged, items[i])
+	}
+
+	return append(merged, items[end:]...)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// syntax is what's left of w once the runs mapWordRuns hands to transforms
+// are taken out
+func syntax(w string) string {
+	return strings.Map(func(r rune) rune {
+		if isWordRune(r) {
+			return -1
+		}
+		return r
+	}, w)
+}
+
+var rangeTransforms = []struct {
+	name string
+	f    func(words []string, offset, n int) []string
+	// leet swaps letters for symbols, the rest only rework letters
+	keepsSyntax bool
+}{
+	{"obfuscate", ObfuscateRange, false},
+	{"scramble", ScrambleRange, true},
+	{"repeatChars", RepeatCharsRange, true},
+	{"reverse", ReverseRange, true},
+	{"dropVowels", DropVowelsRange, true},
+	{"homoglyph", HomoglyphRange, true},
+	{"randomCaps", RandomCapsRange, true},
+}
+
+func TestRangeTransformsStayInRange(t *testing.T) {
+	passage := []string{"func", "main()", "{", "\n\t", "fmt.Println(x)", "\n", "}", "done"}
+
+	tests := []struct {
+		offset, n int
+	}{
+		{0, 3},
+		{2, 4},
+		{5, 100},
+		{0, 0},
+		{-1, 2},
+		{len(passage), 2},
+	}
+
+	for _, tr := range rangeTransforms {
+		for _, tt := range tests {
+			words := tr.f(slices.Clone(passage), tt.offset, tt.n)
+			if len(words) != len(passage) {
+				t.Fatalf("%s(%d, %d): got %d words, want %d", tr.name, tt.offset, tt.n, len(words), len(passage))
+			}
+
+			for i, w := range words {
+				inRange := tt.offset >= 0 && i >= tt.offset && i < tt.offset+tt.n
+				switch {
+				case !inRange && w != passage[i]:
+					t.Errorf("%s(%d, %d): word %d changed out of range, %q to %q", tr.name, tt.offset, tt.n, i, passage[i], w)
+				case isLayout(passage[i]) && w != passage[i]:
+					t.Errorf("%s(%d, %d): layout unit %d changed to %q", tr.name, tt.offset, tt.n, i, w)
+				case tr.keepsSyntax && syntax(w) != syntax(passage[i]):
+					t.Errorf("%s(%d, %d): syntax of %q changed, got %q", tr.name, tt.offset, tt.n, passage[i], w)
+				}
+			}
+		}
+	}
+}
+
+func TestScrambleRangeKeepsLetters(t *testing.T) {
+	tests := []string{"a", "word", "snake_case", "x[i]", "héllo"}
+
+	for _, w := range tests {
+		got := ScrambleRange([]string{w}, 0, 1)[0]
+
+		want, have := []rune(w), []rune(got)
+		slices.Sort(want)
+		slices.Sort(have)
+		if !slices.Equal(want, have) {
+			t.Errorf("ScrambleRange(%q) = %q, not the same letters", w, got)
+		}
+	}
+}
+
+func TestRepeatCharsRange(t *testing.T) {
+	tests := []string{"a", "hello", "f(x)", "n1"}
+
+	for _, w := range tests {
+		var pattern strings.Builder
+		for _, r := range w {
+			pattern.WriteString(regexp.QuoteMeta(string(r)))
+			if isWordRune(r) {
+				pattern.WriteString("{1,3}")
+			}
+		}
+		re := regexp.MustCompile("^" + pattern.String() + "$")
+
+		for range 20 {
+			if got := RepeatCharsRange([]string{w}, 0, 1)[0]; !re.MatchString(got) {
+				t.Errorf("RepeatCharsRange(%q) = %q, want each letter 1 to 3 times", w, got)
+			}
+		}
+	}
+}
+
+func TestObfuscateRangeInCode(t *testing.T) {
+	// code only gets letters swapped one for one, nothing added
+	words := []string{"return", "\n\t", "total"}
+	got := ObfuscateRange(slices.Clone(words), 0, len(words))
+
+	for i := range words {
+		if len([]rune(got[i])) != len([]rune(words[i])) {
+			t.Errorf("ObfuscateRange in code changed %q to %q", words[i], got[i])
+		}
+	}
+}
+
+func TestMapWordRuns(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"word", "WORD"},
+		{"...", "..."},
+		{"a.b", "A.B"},
+		{"foo(bar, baz_1)", "FOO(BAR, BAZ_1)"},
+		{"(x)", "(X)"},
+		{"héllo!", "HÉLLO!"},
+	}
+
+	for _, tt := range tests {
+		if got := mapWordRuns(tt.in, strings.ToUpper); got != tt.want {
+			t.Errorf("mapWordRuns(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReverseRange(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"hello", "olleh"},
+		{"f(ab)", "f(ba)"},
+		{"a.bc", "a.cb"},
+		{"\n\t", "\n\t"},
+	}
+
+	for _, tt := range tests {
+		if got := ReverseRange([]string{tt.in}, 0, 1)[0]; got != tt.want {
+			t.Errorf("ReverseRange(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDropVowelsRange(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"apple", "appl"},
+		{"queue", "q"},
+		{"aeiou", "a"},
+		{"obj.items", "obj.itms"},
+		{"rhythm", "rhythm"},
+	}
+
+	for _, tt := range tests {
+		if got := DropVowelsRange([]string{tt.in}, 0, 1)[0]; got != tt.want {
+			t.Errorf("DropVowelsRange(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// lookalikes is every way homoglyph could rewrite s
+func lookalikes(s string) []string {
+	out := []string{""}
+	for _, r := range s {
+		var next []string
+		for _, o := range out {
+			next = append(next, o+string(r))
+			if repl, ok := homoglyphs[r]; ok {
+				next = append(next, o+string(repl))
+			}
+		}
+		out = next
+	}
+	return out
+}
+
+func TestHomoglyphRange(t *testing.T) {
+	tests := []string{"mild", "wOOd", "tree", "x1", "Space"}
+
+	for _, w := range tests {
+		want := lookalikes(w)
+		for range 20 {
+			got := HomoglyphRange([]string{w}, 0, 1)[0]
+			if !slices.Contains(want, got) {
+				t.Errorf("HomoglyphRange(%q) = %q, want one of %q", w, got, want)
+			}
+			if plain := plainText(got); plain != w {
+				t.Errorf("plainText(%q) = %q, want %q", got, plain, w)
+			}
+		}
+	}
+}
+
+func TestRandomCapsRange(t *testing.T) {
+	tests := []string{"word", "MiXeD", "x.y_z", "über"}
+
+	for _, w := range tests {
+		if got := RandomCapsRange([]string{w}, 0, 1)[0]; !strings.EqualFold(got, w) {
+			t.Errorf("RandomCapsRange(%q) = %q, not the same letters", w, got)
+		}
+	}
+}
+
+func TestMergeRange(t *testing.T) {
+	tests := []struct {
+		words     []string
+		offset, n int
+		want      []string
+	}{
+		{[]string{"a", "b", "c", "d", "e"}, 0, 4, []string{"ab", "cd", "e"}},
+		{[]string{"a", "b", "c", "d", "e"}, 1, 3, []string{"a", "bc", "d", "e"}},
+		{[]string{"a", "b", "c"}, 0, 100, []string{"ab", "c"}},
+		{[]string{"a", "\n", "b", "c"}, 0, 4, []string{"a", "\n", "bc"}},
+		{[]string{"a", "b"}, 0, 1, []string{"a", "b"}},
+		{[]string{"a", "b"}, 0, 0, []string{"a", "b"}},
+		{[]string{"a", "b"}, 2, 2, []string{"a", "b"}},
+		{[]string{"a", "b"}, -1, 2, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		in := slices.Clone(tt.words)
+		if got := MergeRange(in, tt.offset, tt.n); !slices.Equal(got, tt.want) {
+			t.Errorf("MergeRange(%q, %d, %d) = %q, want %q", tt.words, tt.offset, tt.n, got, tt.want)
+		}
+		if !slices.Equal(in, tt.words) {
+			t.Errorf("MergeRange(%q, %d, %d) changed its input to %q", tt.words, tt.offset, tt.n, in)
+		}
+	}
+}
+
+func TestMergePairs(t *testing.T) {
+	// 0 stands in for layout, pairs are summed
+	layout := func(i int) bool { return i == 0 }
+	sum := func(a, b int) int { return a + b }
+
+	tests := []struct {
+		items     []int
+		offset, n int
+		want      []int
+	}{
+		{[]int{1, 2, 3, 4}, 0, 4, []int{3, 7}},
+		{[]int{1, 2, 3, 4, 5}, 0, 5, []int{3, 7, 5}},
+		{[]int{1, 0, 2, 3}, 0, 4, []int{1, 0, 5}},
+		{[]int{1, 2, 0, 3}, 1, 3, []int{1, 2, 0, 3}},
+		{[]int{1, 2, 3, 4}, 2, 2, []int{1, 2, 7}},
+		{[]int{1, 2}, 0, 0, []int{1, 2}},
+		{nil, 0, 2, nil},
+	}
+
+	for _, tt := range tests {
+		if got := mergePairs(tt.items, tt.offset, tt.n, layout, sum); !slices.Equal(got, tt.want) {
+			t.Errorf("mergePairs(%v, %d, %d) = %v, want %v", tt.items, tt.offset, tt.n, got, tt.want)
+		}
+	}
+}
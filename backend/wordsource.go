@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// WordSource hands a lobby the words for its race
+type WordSource interface {
+	// Name identifies the source the way a lobby creator picks it
+	Name() string
+	Words(n int) []string
+}
+
+// a Passage is raced whole and in order instead of WordCount random words
+type Passage interface {
+	Passage() []string
+}
+
+// ---- Language packs ----
+//
+// Packs are embedded from languages/<language>-<size>.txt, one word per line,
+// most common first. A size is how many of a language's most common words
+// the pack holds, and every language ships every size.
+
+//go:embed languages/*.txt
+var languageFiles embed.FS
+
+const defaultLanguage = "en"
+const defaultPackSize = "200"
+
+// packSizes is every size a language has a pack for, smallest first
+var packSizes = []string{"200", "1k", "10k"}
+
+type languagePack struct {
+	language string
+	size     string
+	words    []string
+}
+
+func (p *languagePack) Name() string {
+	return p.language + "-" + p.size
+}
+
+func (p *languagePack) Words(n int) []string {
+	return RandomWords(p.words, n)
+}
+
+// ---- Uploaded lists ----
+
+const uploadedPrefix = "list:"
+
+var wordListNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+const wordListMaxBytes = 1 << 20
+const wordListMinWords = 10
+
+type uploadedList struct {
+	name  string
+	words []string
+}
+
+func (u *uploadedList) Name() string {
+	return uploadedPrefix + u.name
+}
+
+func (u *uploadedList) Words(n int) []string {
+	return RandomWords(u.words, n)
+}
+
+// readWordList splits r on whitespace. Words go to clients with a one byte
+// length, so anything longer is refused.
+func readWordList(r io.Reader) ([]string, error) {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		w := scanner.Text()
+		if len(w) > 255 {
+			return nil, fmt.Errorf("word %.16q... is longer than 255 bytes", w)
+		}
+		words = append(words, w)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// ---- Sources ----
+
+type wordSources struct {
+	// packs by name, like en-200
+	packs map[string]*languagePack
+
+	quotes   *quoteCorpus
+	snippets *snippetCorpus
+
+	// where uploaded lists live, empty to not take uploads
+	dir string
+}
+
+func loadWordSources(dir string) (*wordSources, error) {
+	s := &wordSources{
+		packs: make(map[string]*languagePack),
+		dir:   dir,
+	}
+
+	quotes, err := loadQuotes()
+	if err != nil {
+		return nil, err
+	}
+	s.quotes = quotes
+
+	snippets, err := loadSnippets()
+	if err != nil {
+		return nil, err
+	}
+	s.snippets = snippets
+
+	files, err := languageFiles.ReadDir("languages")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), ".txt")
+		language, size, ok := strings.Cut(name, "-")
+		if !ok {
+			return nil, fmt.Errorf("language pack %s: expected <language>-<size>.txt", f.Name())
+		}
+		if !slices.Contains(packSizes, size) {
+			return nil, fmt.Errorf("language pack %s: size must be one of %v", f.Name(), packSizes)
+		}
+
+		data, err := languageFiles.ReadFile(path.Join("languages", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		words, err := readWordList(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("language pack %s: %w", f.Name(), err)
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("language pack %s is empty", f.Name())
+		}
+
+		s.packs[name] = &languagePack{language: language, size: size, words: words}
+	}
+
+	if _, ok := s.packs[defaultLanguage+"-"+defaultPackSize]; !ok {
+		return nil, fmt.Errorf("missing default language pack %s-%s", defaultLanguage, defaultPackSize)
+	}
+	for _, p := range s.packs {
+		for _, size := range packSizes {
+			if _, ok := s.packs[p.language+"-"+size]; !ok {
+				return nil, fmt.Errorf("missing language pack %s-%s", p.language, size)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+func (s *wordSources) defaultSource() WordSource {
+	return s.packs[defaultLanguage+"-"+defaultPackSize]
+}
+
+// resolve picks a source the way a lobby creator names it: a pack size with
+// a language, list:<name> for an uploaded list, quote with an optional
+// :short, :medium or :long, or code with a programming language. Empty means
+// the default.
+func (s *wordSources) resolve(source, language string) (WordSource, error) {
+	if name, ok := strings.CutPrefix(source, uploadedPrefix); ok {
+		return s.open(name)
+	}
+	if kind, length, _ := strings.Cut(source, ":"); kind == quoteSourceName {
+		return s.quotes.resolve(length)
+	}
+	if source == codeSourceName {
+		return s.snippets.resolve(language)
+	}
+
+	if source == "" {
+		source = defaultPackSize
+	}
+	if language == "" {
+		language = defaultLanguage
+	}
+
+	size, language := strings.ToLower(source), strings.ToLower(language)
+	if !slices.Contains(packSizes, size) {
+		return nil, fmt.Errorf("no word pack of size %q, pick one of %v", source, packSizes)
+	}
+	p, ok := s.packs[language+"-"+size]
+	if !ok {
+		return nil, fmt.Errorf("no word packs for language %q", language)
+	}
+	return p, nil
+}
+
+func (s *wordSources) listPath(name string) (string, bool) {
+	if s.dir == "" || !wordListNamePattern.MatchString(name) {
+		return "", false
+	}
+	return filepath.Join(s.dir, name+".txt"), true
+}
+
+// lists are read whenever a lobby asks, so a re-upload takes effect for the
+// next lobby
+func (s *wordSources) open(name string) (WordSource, error) {
+	p, ok := s.listPath(name)
+	if !ok {
+		return nil, fmt.Errorf("no word list %q", name)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	words, err := readWordList(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("word list %q is empty", name)
+	}
+
+	return &uploadedList{name: name, words: words}, nil
+}
+
+func (s *wordSources) uploads() ([]string, error) {
+	if s.dir == "" {
+		return []string{}, nil
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".txt"); ok && wordListNamePattern.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// ---- HTTP ----
+
+// everything resolve takes, see there for how each is named
+type wordSourceListing struct {
+	// pack sizes by language
+	Languages map[string][]string `json:"languages"`
+	Uploads   []string            `json:"uploads"`
+	// quote lengths, each raced as quote:<length>
+	Quotes []string `json:"quotes"`
+	// programming languages with code snippets
+	Code []string `json:"code"`
+}
+
+func (s *wordSources) ServeList(w http.ResponseWriter, r *http.Request) {
+	uploads, err := s.uploads()
+	if err != nil {
+		log.Printf("listing word lists: %s", err)
+		http.Error(w, "could not list word lists", http.StatusInternalServerError)
+		return
+	}
+
+	listing := wordSourceListing{
+		Languages: make(map[string][]string),
+		Uploads:   uploads,
+		Quotes:    quoteLengthNames[:],
+		Code:      s.snippets.languages(),
+	}
+	// every language has every size, loadWordSources checks
+	for _, p := range s.packs {
+		listing.Languages[p.language] = packSizes
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listing)
+}
+
+// ServeUpload saves a whitespace separated word list under the name in the
+// path, replacing any list already there
+func (s *wordSources) ServeUpload(w http.ResponseWriter, r *http.Request) {
+	if s.dir == "" {
+		http.Error(w, "word list uploads are disabled", http.StatusForbidden)
+		return
+	}
+
+	name := r.PathValue("name")
+	p, ok := s.listPath(name)
+	if !ok {
+		http.Error(w, "names are 1 to 32 letters, digits, - or _", http.StatusBadRequest)
+		return
+	}
+
+	words, err := readWordList(http.MaxBytesReader(w, r.Body, wordListMaxBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(words) < wordListMinWords {
+		http.Error(w, fmt.Sprintf("a word list needs at least %d words", wordListMinWords), http.StatusBadRequest)
+		return
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		log.Printf("saving word list %s: %s", name, err)
+		http.Error(w, "could not save word list", http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(p, []byte(strings.Join(words, "\n")+"\n"), 0o644); err != nil {
+		log.Printf("saving word list %s: %s", name, err)
+		http.Error(w, "could not save word list", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("word list %s saved with %d words", name, len(words))
+	w.WriteHeader(http.StatusCreated)
+}
@@ -22,7 +22,7 @@ export const AnimationContext = createContext<{
 });
 
 function App() {
-	const { page, players, currentPlayer } = usePage();
+	const { page, players, currentPlayer, results } = usePage();
 	const [visible, setVisible] = useState(false);
 	const [ready, setReady] = useState(false);
 	const [animationEnd, setAnimationEnd] = useState(false);
@@ -35,7 +35,7 @@ function App() {
 
 	function currentPage() {
 		if (ready && animationEnd) {
-			if (players[currentPlayer]?.finished === true) {
+			if (players[currentPlayer]?.finished === true || results !== null) {
 				return <FinishPage />;
 			} else if (page === CurrentPage.Login) {
 				return <TitlePage />;
@@ -13,8 +13,17 @@ import type {
 	UpdateWords,
 	StatusChanged,
 	Purchase,
+	LobbyCode,
+	JoinFailed,
+	JoinFailedReason,
+	WordsSkipped,
+	SubmissionRejected,
+	ResumeState,
+	RaceResults,
+	RaceCountdown,
+	Balance,
 } from "./lib/comm.ts";
-import { connect as socketConnect } from "./lib/comm.ts";
+import { connect as socketConnect, ServerOp } from "./lib/comm.ts";
 import gamestate from "./lib/gamestate.ts";
 
 export const CurrentPage = {
@@ -32,6 +41,26 @@ export type PurchaseSuccess = {
 	success: boolean;
 };
 
+// how to get into a lobby, matchmaking unless a code is given or create is set.
+// A resume token picks a dropped race back up instead.
+export type Join = {
+	code?: string;
+	create?: boolean;
+	resumeToken?: string;
+	attempt?: number;
+};
+
+// a dropped connection is retried this often before giving up on the race
+const resumeAttempts = 5;
+const resumeDelay = 1000;
+
+// the server moved the player to word `to`, the words from `from` up to it
+// count as typed and anything after is still to type
+export type WordJump = {
+	from: number;
+	to: number;
+};
+
 type PageContextType = {
 	socket: Socket;
 	page: CurrentPage;
@@ -46,6 +75,18 @@ type PageContextType = {
 	powerups: PowerupId[];
 	setPowerups: React.Dispatch<React.SetStateAction<PowerupId[]>>;
 	purchaseSuccess: PurchaseSuccess;
+	setJoin: React.Dispatch<React.SetStateAction<Join>>;
+	// the private lobby's join code, empty for public lobbies
+	lobbyCode: string;
+	joinFailed: JoinFailedReason | null;
+	wordJump: WordJump | null;
+	// coins to buy powerups with, and the words typed in a row without a mistake
+	balance: Balance;
+	// seconds before unfinished players run out of time
+	raceTime: number;
+	// the final standings once the race is over
+	results: RaceResults | null;
+	setResults: React.Dispatch<React.SetStateAction<RaceResults | null>>;
 };
 
 const PageContext = createContext<PageContextType | undefined>(undefined);
@@ -58,22 +99,36 @@ function connect(
 	setPage: React.Dispatch<React.SetStateAction<CurrentPage>>,
 	setPurchaseSuccess: React.Dispatch<React.SetStateAction<PurchaseSuccess>>,
 	setCurrentPlayer: React.Dispatch<React.SetStateAction<number>>,
-	setPowerups: React.Dispatch<React.SetStateAction<PowerupId[]>>
-): (name: string) => Promise<void> {
-	return async (name: string) => {
+	setPowerups: React.Dispatch<React.SetStateAction<PowerupId[]>>,
+	setLobbyCode: React.Dispatch<React.SetStateAction<string>>,
+	setJoinFailed: React.Dispatch<React.SetStateAction<JoinFailedReason | null>>,
+	setName: React.Dispatch<React.SetStateAction<string>>,
+	setWordJump: React.Dispatch<React.SetStateAction<WordJump | null>>,
+	setResults: React.Dispatch<React.SetStateAction<RaceResults | null>>,
+	setRaceTime: React.Dispatch<React.SetStateAction<number>>,
+	setBalance: React.Dispatch<React.SetStateAction<Balance>>
+): (name: string, join: Join) => Promise<void> {
+	const start = async (name: string, join: Join) => {
 		const socket = await socketConnect();
+		// empty once there's no race left to come back to
+		let resumeToken = join.resumeToken ?? "";
+		let attempt = join.attempt ?? 0;
 		socket.event.onHubHello((_) => {
 			console.log("received hub hello");
 		});
 		socket.event.onLobbyHello((m: LobbyHello) => {
+			resumeToken = m.resumeToken;
 			setPlayers((pm) =>
 				m.players.reduce<PlayerMap>((a, c) => {
 					return { [c.id]: c, ...a };
 				}, pm)
 			);
 			setWords(m.words);
+			setWordJump(null);
+			setBalance({ opcode: ServerOp.Balance, balance: 0, streak: 0 });
 			setTime(m.timeLeft);
-			setPage(CurrentPage.Lobby);
+			// a resumed race keeps its page, the greeting is sent again
+			setPage((p) => (p === CurrentPage.Game ? p : CurrentPage.Lobby));
 			setCurrentPlayer(m.playerId);
 			setPowerups(m.powerups);
 		});
@@ -86,6 +141,8 @@ function connect(
 			setPlayers((i) => {
 				i[m.id].finished = true;
 				i[m.id].place = m.place;
+				i[m.id].wpm = m.wpm;
+				i[m.id].accuracy = m.accuracy;
 				return { ...i };
 			});
 		});
@@ -93,6 +150,7 @@ function connect(
 			setPlayers((i) => {
 				i[m.playerId].progress = m.progress;
 				i[m.playerId].wpm = m.wpm;
+				i[m.playerId].accuracy = m.accuracy;
 				return { ...i };
 			});
 		});
@@ -107,21 +165,78 @@ function connect(
 		});
 		socket.event.onPurchaseResult((m: PurchaseResult) => {
 			setPurchaseSuccess({ powerupId: m.powerupId, success: m.success });
+			if (m.balance !== undefined) {
+				const balance = m.balance;
+				setBalance((b) => ({ ...b, balance }));
+			}
+		});
+		socket.event.onBalance((m: Balance) => {
+			setBalance(m);
 		});
 		socket.event.onUpdateWords((m: UpdateWords) => {
 			setWords((i) => {
 				return i.slice(0, m.startIndex).concat(m.words);
 			});
 		});
+		socket.event.onSubmissionRejected((m: SubmissionRejected) => {
+			// the server is still waiting on m.idx, whatever came after didn't count
+			setWordJump({ from: m.idx, to: m.idx });
+		});
+		socket.event.onWordsSkipped((m: WordsSkipped) => {
+			// autocorrect typed these, so don't make them type them again
+			setWordJump({ from: m.from, to: m.to });
+		});
+		socket.event.onRaceCountdown((m: RaceCountdown) => {
+			setRaceTime(m.timeLeft);
+		});
+		socket.event.onRaceResults((m: RaceResults) => {
+			// the lobby closes after this, there's nothing to resume
+			resumeToken = "";
+			setResults(m);
+		});
+		socket.event.onResumeState((m: ResumeState) => {
+			attempt = 0;
+			setWordJump({ from: 0, to: m.idx });
+			setPowerups(m.powerups);
+		});
+		socket.event.onLobbyCode((m: LobbyCode) => {
+			setLobbyCode(m.code);
+		});
+		socket.event.onJoinFailed((m: JoinFailed) => {
+			// the server hangs up next, clearing the name lets them try again
+			if (join.resumeToken === undefined) {
+				setJoinFailed(m.reason);
+			}
+			resumeToken = "";
+			setName("");
+		});
 		console.log(socket);
-		socket.socket.addEventListener("open", (_) =>
-			socket.sendRegister(name)
-		);
-		socket.socket.addEventListener("close", (_) =>
-			setPage(CurrentPage.Login)
-		);
+		socket.socket.addEventListener("open", (_) => {
+			if (join.resumeToken !== undefined) {
+				socket.sendResume(join.resumeToken);
+				return;
+			}
+			setJoinFailed(null);
+			setLobbyCode("");
+			if (join.create) {
+				socket.sendCreateLobby(name);
+			} else {
+				socket.sendRegister(name, join.code);
+			}
+		});
+		socket.socket.addEventListener("close", (_) => {
+			if (resumeToken === "" || attempt >= resumeAttempts) {
+				setPage(CurrentPage.Login);
+				return;
+			}
+			setTimeout(
+				() => start(name, { resumeToken, attempt: attempt + 1 }),
+				resumeDelay
+			);
+		});
 		setSocket(socket);
 	};
+	return start;
 }
 
 export function PageProvider({ children }: { children: React.ReactNode }) {
@@ -136,6 +251,17 @@ export function PageProvider({ children }: { children: React.ReactNode }) {
 	);
 	const [powerups, setPowerups] = useState([] as PowerupId[]);
 	const [currentPlayer, setCurrentPlayer] = useState(0);
+	const [join, setJoin] = useState({} as Join);
+	const [lobbyCode, setLobbyCode] = useState("");
+	const [joinFailed, setJoinFailed] = useState(null as JoinFailedReason | null);
+	const [wordJump, setWordJump] = useState(null as WordJump | null);
+	const [results, setResults] = useState(null as RaceResults | null);
+	const [raceTime, setRaceTime] = useState(0);
+	const [balance, setBalance] = useState({
+		opcode: ServerOp.Balance,
+		balance: 0,
+		streak: 0,
+	} as Balance);
 	useEffect(() => {
 		console.log("name: '" + name + "'");
 		if (name === "") {
@@ -150,8 +276,15 @@ export function PageProvider({ children }: { children: React.ReactNode }) {
 			setPage,
 			setPurchaseSucces,
 			setCurrentPlayer,
-			setPowerups
-		)(name);
+			setPowerups,
+			setLobbyCode,
+			setJoinFailed,
+			setName,
+			setWordJump,
+			setResults,
+			setRaceTime,
+			setBalance
+		)(name, join);
 		return () => {};
 	}, [name]);
 	useEffect(() => {
@@ -185,6 +318,14 @@ export function PageProvider({ children }: { children: React.ReactNode }) {
 				powerups,
 				setPowerups,
 				currentPlayer,
+				setJoin,
+				lobbyCode,
+				joinFailed,
+				wordJump,
+				balance,
+				raceTime,
+				results,
+				setResults,
 			}}
 		>
 			{children}
@@ -3,7 +3,7 @@ import { usePage } from "@/PageProvider";
 import { Reorder, motion } from "framer-motion";
 
 function LobbyList() {
-	const { players, currentPlayer } = usePage();
+	const { players, currentPlayer, lobbyCode } = usePage();
 
 	const orderedPlayers: Player[] = Object.values(players).sort((a, b) => {
 		if (a.progress === b.progress) {
@@ -15,7 +15,14 @@ function LobbyList() {
 
 	return (
 		<div className="absolute top-0 left-0 flex flex-col bg-card m-4 rounded-md font-mono w-3xs">
-			<div className="w-full p-4 text-center rounded-md">OverTyped</div>
+			<div className="w-full p-4 text-center rounded-md">
+				OverTyped
+				{lobbyCode !== "" && (
+					<div className="text-sm text-muted-foreground">
+						code {lobbyCode}
+					</div>
+				)}
+			</div>
 			<Reorder.Group
 				axis="y"
 				values={orderedPlayers.map((p) => p.id)}
@@ -3,9 +3,11 @@ import { CurrentPage, usePage } from "@/PageProvider";
 import { useContext } from "react";
 import { setStage } from "@/lib/draw-scene";
 import { Button } from "../ui/button";
+import { ResultStatus, type PlayerResult } from "@/lib/comm";
 
 function FinishPage() {
-	const { setName, players, setPlayers, currentPlayer } = usePage();
+	const { setName, players, setPlayers, currentPlayer, results, setResults } =
+		usePage();
 	const { visible, setVisible, setReady, setAnimationEnd } =
 		useContext(AnimationContext);
 
@@ -15,6 +17,7 @@ function FinishPage() {
 			setReady(false);
 			setAnimationEnd(false);
 			setPlayers({});
+			setResults(null);
 			setName("");
 			setStage(0);
 			setTimeout(() => {
@@ -38,12 +41,53 @@ function FinishPage() {
 		}
 	}
 
+	function standing(r: PlayerResult): string {
+		switch (r.status) {
+			case ResultStatus.DidNotFinish:
+				return "DNF";
+			case ResultStatus.Disconnected:
+				return "left";
+			default:
+				return placeName(r.place);
+		}
+	}
+
+	const own = results?.results.find((r) => r.playerId === currentPlayer);
+	const heading =
+		own === undefined
+			? `Finished ${placeName(players[currentPlayer]?.place ?? 0)} Place`
+			: own.status === ResultStatus.Finished
+				? `Finished ${placeName(own.place)} Place`
+				: "Out of Time";
+	const wpm = own?.wpm ?? players[currentPlayer]?.wpm ?? 0;
+
 	return (
 		<div
 			className={`w-full h-full flex flex-col justify-center items-center gap-4 ${visible ? "opacity-100" : "opacity-0"}`}
 		>
-			<h2 className="text-2xl font-bold">Finished {placeName(players[currentPlayer].place)} Place</h2>
-			<p className="text-lg">Average WPM: {players[currentPlayer].wpm}</p>
+			<h2 className="text-2xl font-bold">{heading}</h2>
+			<p className="text-lg">Average WPM: {wpm}</p>
+			{results === null ? (
+				<p className="text-muted-foreground">Waiting on the others...</p>
+			) : (
+				<table className="font-mono">
+					<tbody>
+						{results.results.map((r) => (
+							<tr
+								key={r.playerId}
+								className={r.playerId === currentPlayer ? "bg-muted" : ""}
+							>
+								<td className="px-3">{standing(r)}</td>
+								<td className="px-3">{players[r.playerId]?.name}</td>
+								<td className="px-3 text-right">{r.wpm} WPM</td>
+								<td className="px-3 text-right">
+									{Math.round(r.accuracy * 100)}%
+								</td>
+							</tr>
+						))}
+					</tbody>
+				</table>
+			)}
 			<Button
 				className="border border-primary"
 				onClick={reset}
@@ -5,6 +5,7 @@ import Draft from "../lobby/Draft";
 import { useEffect, useState } from "react";
 import { setPlayerCount } from "@/lib/draw-scene";
 import Countdown from "../lobby/Countdown";
+import RaceTimer from "../typing/RaceTimer";
 
 function GamePage() {
 	const { page, words, players } = usePage();
@@ -22,7 +23,10 @@ function GamePage() {
 		<>
 			<LobbyList />
 			{page === CurrentPage.Game ? (
-				<Typing words={words} />
+				<>
+					<RaceTimer />
+					<Typing words={words} />
+				</>
 			) : (
 				<>
 					<Countdown
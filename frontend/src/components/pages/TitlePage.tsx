@@ -2,18 +2,38 @@ import { CurrentPage, usePage } from "@/PageProvider";
 import { useEffect, useRef, useState, type KeyboardEventHandler } from "react";
 import FadeTypewriter from "../FadeTypewriter";
 import { Spinner } from "@/components/ui/spinner";
+import { JoinFailedReason } from "@/lib/comm";
+
+const joinFailedText: { [key in JoinFailedReason]: string } = {
+	[JoinFailedReason.NotFound]: "No lobby with that code",
+	[JoinFailedReason.Full]: "That lobby is full",
+	[JoinFailedReason.Started]: "That race already started",
+	[JoinFailedReason.WordSource]: "Those words aren't available",
+	[JoinFailedReason.Mode]: "That race mode isn't available",
+	[JoinFailedReason.NotSeeded]: "That heat is for seeded players only",
+};
 
 function TitlePage() {
-	const { page, setName } = usePage();
+	const { page, setName, setJoin, joinFailed } = usePage();
 
 	const [tempName, setTempName] = useState("");
+	const [code, setCode] = useState("");
 	const [loading, setLoading] = useState(false);
 	const inputRef = useRef<HTMLInputElement>(null);
 
+	function start(create: boolean) {
+		if (tempName === "") {
+			inputRef.current?.focus();
+			return;
+		}
+		setJoin(create ? { create } : { code: code.trim() || undefined });
+		setName(tempName);
+		setLoading(true);
+	}
+
 	const handleKeyDown: KeyboardEventHandler<HTMLInputElement> = (e) => {
-		if (e.code === "Enter" && tempName !== "") {
-			setName(tempName);
-			setLoading(true);
+		if (e.code === "Enter") {
+			start(false);
 		}
 	};
 
@@ -23,6 +43,12 @@ function TitlePage() {
 		}
 	}, [page]);
 
+	useEffect(() => {
+		if (joinFailed !== null) {
+			setLoading(false);
+		}
+	}, [joinFailed]);
+
 	function refocus() {
 		inputRef.current?.focus();
 	}
@@ -51,6 +77,31 @@ function TitlePage() {
 				onKeyDown={handleKeyDown}
 				maxLength={15}
 			></input>
+			<div className="flex items-center gap-4 text-lg">
+				<input
+					className="w-40 text-center uppercase bg-transparent border-b border-primary/50 outline-none placeholder:normal-case"
+					placeholder="lobby code"
+					value={code}
+					onChange={(e) => setCode(e.target.value)}
+					onKeyDown={handleKeyDown}
+					onClick={(e) => e.stopPropagation()}
+					maxLength={6}
+				></input>
+				<span>or</span>
+				<span
+					role="button"
+					className="underline cursor-pointer"
+					onClick={(e) => {
+						e.stopPropagation();
+						start(true);
+					}}
+				>
+					create a private lobby
+				</span>
+			</div>
+			{joinFailed !== null && (
+				<p className="text-red-400">{joinFailedText[joinFailed]}</p>
+			)}
 		</button>
 	);
 }
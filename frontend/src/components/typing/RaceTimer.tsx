@@ -0,0 +1,37 @@
+import { usePage } from "@/PageProvider";
+import { useEffect, useState } from "react";
+
+// counts down to the race's time limit, resynced whenever the server says
+function RaceTimer() {
+	const { raceTime } = usePage();
+	const [timer, setTimer] = useState(raceTime);
+
+	useEffect(() => {
+		setTimer(raceTime);
+
+		const interval = setInterval(() => {
+			setTimer((t) => {
+				if (t <= 1) {
+					clearInterval(interval);
+					return 0;
+				}
+				return t - 1;
+			});
+		}, 1000);
+
+		return () => clearInterval(interval);
+	}, [raceTime]);
+
+	const minutes = Math.floor(timer / 60);
+	const seconds = timer % 60;
+
+	return (
+		<div
+			className={`absolute top-6 right-6 font-mono text-2xl ${timer <= 10 ? "text-red-400" : "text-muted-foreground"}`}
+		>
+			{minutes}:{seconds.toString().padStart(2, "0")}
+		</div>
+	);
+}
+
+export default RaceTimer;
@@ -29,7 +29,7 @@ export default function Typing({ words }: Props) {
 	const [selectedPowerup, setSelectedPowerup] = useState(0);
 	const [selectedTarget, setSelectedTarget] = useState(0);
 
-	const { socket, powerups, players, currentPlayer } = usePage();
+	const { socket, powerups, players, currentPlayer, balance } = usePage();
 
 	const usedPowerupsRef = useRef<number[]>([]);
 	const selectedPowerupRef = useRef(0);
@@ -37,9 +37,14 @@ export default function Typing({ words }: Props) {
 	const powerupsRef = useRef(powerups);
 	const playersRef = useRef(players);
 	const currentPlayerRef = useRef(currentPlayer);
+	// replaced when a dropped connection is resumed
+	const socketRef = useRef(socket);
+	socketRef.current = socket;
+	const handleEnterRef = useRef(handleEnter);
+	handleEnterRef.current = handleEnter;
 
 	const charSize = useMonoCharSize("font-4xl");
-	const { input, currentWord, typedWords, handleInput } = useTypingEngine(
+	const { input, currentWord, typedWords, handleInput, handleEnter } = useTypingEngine(
 		words,
 		caretRef,
 		writingDivRef,
@@ -82,6 +87,9 @@ export default function Typing({ words }: Props) {
 					break;
 
 				case "Enter": {
+					// line breaks in code races come first
+					if (handleEnterRef.current()) break;
+
 					const sel = selectedPowerupRef.current;
 					const targetIndex = selectedTargetRef.current;
 					const powerups = powerupsRef.current;
@@ -98,7 +106,7 @@ export default function Typing({ words }: Props) {
 
 					if (target === undefined) return;
 
-					socket.sendPurchase({
+					socketRef.current.sendPurchase({
 						powerupId: POWERUP_INFO[powerups[sel]].id,
 						targetPlayer: target,
 					});
@@ -167,6 +175,13 @@ export default function Typing({ words }: Props) {
 						</div>
 					))}
 				</div>
+				<div
+					className="ml-10 text-nowrap text-foreground"
+					title="coins, and words in a row without a mistake"
+				>
+					{balance.balance} coins
+					{balance.streak > 1 && ` \u00B7 ${balance.streak} streak`}
+				</div>
 				<img
 					src={leftright}
 					className="size-5 ml-6"
@@ -1,6 +1,7 @@
 import { Powerup } from "@/lib/comm";
 import { usePage } from "@/PageProvider";
 import { memo, useMemo } from "react";
+import { isLayout, plainText } from "@/hooks/useTypingEngine";
 
 type LetterStatus = "pending" | "correct" | "incorrect";
 
@@ -32,7 +33,7 @@ const Word = memo(function Word({ word, isActive, status, input, offsetY }: Prop
 
 		return word.split("").map((char, i) => {
 			if (i >= input.length) return "pending";
-			return input[i] === char ? "correct" : "incorrect";
+			return input[i] === plainText(char) ? "correct" : "incorrect";
 		});
 	}, [input, isActive, word, status]);
 
@@ -58,6 +59,31 @@ const Word = memo(function Word({ word, isActive, status, input, offsetY }: Prop
 
 	const className = "mx-[0.25em] relative transition-colors ";
 
+	// a line break shows as a return mark, then a full width break so the
+	// next word wraps, then the indentation. The box is dropped so the caret
+	// can still find the mark as the first child.
+	if (isLayout(word)) {
+		const style = { transform: `translateY(-${offsetY}px)` };
+		const color = status === "correct" ? "text-foreground" : "";
+		return (
+			<div className="contents">
+				<span
+					className={`${className} ${color} ${shouldHide ? "opacity-0" : ""}`}
+					style={style}
+				>
+					{"\u21B5"}
+				</span>
+				<span className="basis-full h-0" />
+				<span
+					className="whitespace-pre"
+					style={style}
+				>
+					{word.slice(word.lastIndexOf("\n") + 1)}
+				</span>
+			</div>
+		);
+	}
+
 	return (
 		<div
 			className={`${shouldHide ? "opacity-0" : ""} ${className}`}
@@ -5,13 +5,55 @@ import { useEffect, useRef, useState, type RefObject } from "react";
 
 export type WordStatus = "pending" | "correct" | "incorrect";
 
+// code races have line breaks with the next line's indentation as words of
+// their own, typed with Enter
+export function isLayout(word: string): boolean {
+	return word !== "" && word.trim() === "" && word.includes("\n");
+}
+
+// the mirage powerup swaps letters for Cyrillic lookalikes, which are typed
+// as the letters they look like
+const homoglyphs: Record<string, string> = {
+	"\u0430": "a",
+	"\u0441": "c",
+	"\u0501": "d",
+	"\u0435": "e",
+	"\u04bb": "h",
+	"\u0456": "i",
+	"\u0458": "j",
+	"\u043e": "o",
+	"\u0440": "p",
+	"\u0455": "s",
+	"\u0445": "x",
+	"\u0443": "y",
+	"\u0410": "A",
+	"\u0412": "B",
+	"\u0421": "C",
+	"\u0415": "E",
+	"\u041d": "H",
+	"\u0406": "I",
+	"\u0408": "J",
+	"\u041a": "K",
+	"\u041c": "M",
+	"\u041e": "O",
+	"\u0420": "P",
+	"\u0405": "S",
+	"\u0422": "T",
+	"\u0425": "X",
+};
+
+// plainText is what typing word takes
+export function plainText(word: string): string {
+	return word.replace(/[^\x00-\x7f]/g, (c) => homoglyphs[c] ?? c);
+}
+
 export function useTypingEngine(
 	words: string[],
 	caret: RefObject<HTMLDivElement | null>,
 	container: RefObject<HTMLDivElement | null>,
 	charSize: number
 ) {
-	const { socket } = usePage();
+	const { socket, wordJump } = usePage();
 	const [currentWord, setCurrentWord] = useState(0);
 	const [input, setInput] = useState("");
 	const [typedWords, setTypedWords] = useState(
@@ -35,12 +77,76 @@ export function useTypingEngine(
 		return;
 	}, [words]);
 
+	useEffect(() => {
+		if (wordJump === null) {
+			return;
+		}
+		setTypedWords((prev) =>
+			prev.map((w, i) => {
+				if (i >= wordJump.from && i < wordJump.to) {
+					return { ...w, status: "correct" as WordStatus };
+				}
+				if (i >= wordJump.to) {
+					return { text: words[i] ?? w.text, status: "pending" as WordStatus };
+				}
+				return w;
+			})
+		);
+		setCurrentWord(wordJump.to);
+		setInput("");
+	}, [wordJump]);
+
 	// Cache container width to avoid repeated DOM measurements
 	const containerWidthRef = useRef<number | null>(null);
 
+	// Mistakes since the last submission, reported to the server with it
+	const mistakesRef = useRef({ incorrect: 0, backspaces: 0 });
+
+	const submit = (typed: string) => {
+		setTypedWords((prev) => {
+			const next = [...prev];
+			next[currentWord] = { ...next[currentWord], status: "correct" };
+			return next;
+		});
+
+		const { incorrect, backspaces } = mistakesRef.current;
+		if (incorrect > 0 || backspaces > 0) {
+			socket.sendKeystrokes(incorrect, backspaces);
+			mistakesRef.current = { incorrect: 0, backspaces: 0 };
+		}
+		socket.sendSubmitWord(currentWord, typed);
+		setCurrentWord((w) => w + 1);
+		setInput("");
+	};
+
+	// handleEnter types a line break, returning false when Enter isn't
+	// wanted so it can be used for something else
+	const handleEnter = (): boolean => {
+		const expected = words[currentWord];
+		if (expected === undefined || !isLayout(expected)) {
+			return false;
+		}
+		// the server indents for us, like an editor would
+		submit("\n");
+		return true;
+	};
+
 	const handleInput = (value: string) => {
 		const typed = value.trim();
-		const expected = words[currentWord];
+		const shown = words[currentWord];
+		if (shown === undefined) {
+			return;
+		}
+		const expected = plainText(shown);
+
+		if (isLayout(expected)) {
+			// anything but Enter is a mistake at a line break, bar a space
+			// out of habit
+			if (value.length > input.length && typed !== "") {
+				mistakesRef.current.incorrect += 1;
+			}
+			return;
+		}
 
 		// Only measure if we need to check wrapping
 		const isSubmission =
@@ -80,23 +186,29 @@ export function useTypingEngine(
 			return; // can't type any more
 		}
 
+		if (isBackspace) {
+			mistakesRef.current.backspaces += input.length - value.length;
+		} else {
+			const typedChar = value.length - 1;
+			if (
+				typedChar >= expected.length ||
+				value[typedChar] !== expected[typedChar]
+			) {
+				// a trailing space after a complete word is a submission
+				if (!(typedChar === expected.length && typed === expected)) {
+					mistakesRef.current.incorrect += 1;
+				}
+			}
+		}
+
 		// const sendChars = getTypedCharacters(value, input, expected);
 		// sendChars?.map((char) => socket.sendSubmit(char));
 
 		const isLastWord = currentWord === words.length - 1;
-		if ((value.endsWith(" ") && typed === expected) || (isLastWord && typed === expected && value === expected)) {
-			setTypedWords((prev) => {
-				const next = [...prev];
-				next[currentWord] = {
-					...next[currentWord],
-					status: typed === expected ? "correct" : "incorrect",
-				};
-				return next;
-			});
-
-			socket.sendSubmit(currentWord);
-			setCurrentWord((w) => w + 1);
-			setInput("");
+		// a word right before a line break is done without a space
+		const beforeLayout = isLastWord || isLayout(words[currentWord + 1]);
+		if ((value.endsWith(" ") && typed === expected) || (beforeLayout && typed === expected && value === expected)) {
+			submit(typed);
 			return;
 		}
 
@@ -109,5 +221,6 @@ export function useTypingEngine(
 		typedWords,
 		setInput,
 		handleInput,
+		handleEnter,
 	};
 }
@@ -6,6 +6,15 @@ export const Powerup = {
 	TireBoot: 4,
 	Scrambler: 5,
 	RearViewMirror: 6,
+	ReverseGear: 7,
+	Potholes: 8,
+	Mirage: 9,
+	BumpyRoad: 10,
+	Tailgate: 11,
+	Shield: 12,
+	Nitro: 13,
+	Cleanse: 14,
+	Autocorrect: 15,
 } as const;
 
 export type PowerupId = (typeof Powerup)[keyof typeof Powerup];
@@ -25,11 +34,30 @@ export const ClientOp = {
 	PurchasePowerup: 2,
 	SkipWait: 3,
 	SelectPowerup: 4,
+	CreateLobby: 5,
+	SubmitWord: 6,
+	KeystrokeReport: 7,
+	Resume: 8,
+	Spectate: 9,
+	WatchReplay: 10,
 } as const;
 
 export type RegisterMessage = {
 	opcode: typeof ClientOp.Register;
 	name: string;
+	// joins the private lobby with this code instead of matchmaking
+	code?: string;
+};
+
+// an empty source or language is the server's default word pack
+export type CreateLobbyMessage = {
+	opcode: typeof ClientOp.CreateLobby;
+	name: string;
+	source?: string;
+	language?: string;
+	// seconds, 0 races the whole passage
+	timeAttack?: number;
+	teams?: number;
 };
 
 export type SubmitMessage = {
@@ -37,6 +65,35 @@ export type SubmitMessage = {
 	idx: number;
 };
 
+export type SubmitWordMessage = {
+	opcode: typeof ClientOp.SubmitWord;
+	idx: number;
+	word: string;
+};
+
+export type KeystrokeReportMessage = {
+	opcode: typeof ClientOp.KeystrokeReport;
+	incorrect: number;
+	backspaces: number;
+};
+
+export type ResumeMessage = {
+	opcode: typeof ClientOp.Resume;
+	token: string;
+};
+
+export type SpectateMessage = {
+	opcode: typeof ClientOp.Spectate;
+	lobbyId: number;
+	code?: string;
+};
+
+export type WatchReplayMessage = {
+	opcode: typeof ClientOp.WatchReplay;
+	replayId: string;
+	speed: number;
+};
+
 export type SkipWaitMessage = {
 	opcode: typeof ClientOp.SkipWait;
 };
@@ -52,7 +109,13 @@ export type SelectPowerupMessage = {
 
 export type ClientMessage =
 	| RegisterMessage
+	| CreateLobbyMessage
 	| SubmitMessage
+	| SubmitWordMessage
+	| KeystrokeReportMessage
+	| ResumeMessage
+	| SpectateMessage
+	| WatchReplayMessage
 	| SkipWaitMessage
 	| PurchasePowerupMessage
 	| SelectPowerupMessage;
@@ -62,11 +125,35 @@ export const ServerOp = {
 	LobbyHello: 1,
 	NewPlayer: 2,
 	StartGame: 3,
+	// 4 to 6 are retired on the wire, their V2s are parsed into these
 	ProgressUpdate: 4,
 	PlayerFinished: 5,
 	StatusChanged: 6,
 	PurchaseResult: 7,
 	UpdateWords: 8,
+	LobbyCode: 9,
+	JoinFailed: 10,
+	SubmissionRejected: 11,
+	ProgressUpdateV2: 12,
+	PlayerFinishedV2: 13,
+	RaceResults: 14,
+	RaceCountdown: 15,
+	ResumeState: 16,
+	SpectatorHello: 17,
+	Quote: 18,
+	TeamAssignment: 19,
+	TeamProgress: 20,
+	TeamResults: 21,
+	Balance: 22,
+	PowerupCosts: 23,
+	StatusChangedV2: 24,
+	WordsSkipped: 25,
+} as const;
+
+export const QuoteLength = {
+	Short: 0,
+	Medium: 1,
+	Long: 2,
 } as const;
 
 export type Player = {
@@ -77,6 +164,7 @@ export type Player = {
 	place: number;
 	progress: number;
 	wpm: number;
+	accuracy?: number;
 };
 
 export type HubHello = {
@@ -90,6 +178,8 @@ export type LobbyHello = {
 	players: Player[];
 	words: string[];
 	powerups: PowerupId[];
+	resumeToken: string;
+	lobbyId: number;
 };
 
 export type NewPlayer = {
@@ -105,24 +195,58 @@ export type ProgressUpdate = {
 	playerId: number;
 	progress: number;
 	wpm: number;
+	rawWpm: number;
+	accuracy: number;
 };
 
 export type PlayerFinished = {
 	opcode: typeof ServerOp.PlayerFinished;
 	id: number;
 	place: number;
+	wpm: number;
+	accuracy: number;
+};
+
+export const EffectDurationKind = {
+	Timed: 0,
+	Words: 1,
+} as const;
+
+export type EffectDurationKind =
+	(typeof EffectDurationKind)[keyof typeof EffectDurationKind];
+
+export type EffectState = {
+	powerupId: StatusEffectId;
+	// who it came from, the player themselves for self buffs
+	sourcePlayerId: number;
+	intensity: number;
+	kind: EffectDurationKind;
+	// milliseconds for timed effects, words for the rest
+	remaining: number;
+};
+
+export type CooldownState = {
+	powerupId: PowerupId;
+	// milliseconds
+	remaining: number;
 };
 
 export type StatusChanged = {
 	opcode: typeof ServerOp.StatusChanged;
 	playerId: number;
 	statusEffects: StatusEffectId[];
+	effects: EffectState[];
+	// milliseconds the player can't be hit by powerups
+	immunity: number;
+	cooldowns: CooldownState[];
 };
 
 export type PurchaseResult = {
 	opcode: typeof ServerOp.PurchaseResult;
 	powerupId: PowerupId;
 	success: boolean;
+	// coins left after the purchase
+	balance?: number;
 };
 
 export type UpdateWords = {
@@ -131,6 +255,142 @@ export type UpdateWords = {
 	words: string[];
 };
 
+export type LobbyCode = {
+	opcode: typeof ServerOp.LobbyCode;
+	code: string;
+};
+
+export const JoinFailedReason = {
+	NotFound: 0,
+	Full: 1,
+	Started: 2,
+	WordSource: 3,
+	Mode: 4,
+	NotSeeded: 5,
+} as const;
+
+export type JoinFailedReason =
+	(typeof JoinFailedReason)[keyof typeof JoinFailedReason];
+
+// the server closes the connection after sending this
+export type JoinFailed = {
+	opcode: typeof ServerOp.JoinFailed;
+	reason: JoinFailedReason;
+};
+
+export type SubmissionRejected = {
+	opcode: typeof ServerOp.SubmissionRejected;
+	idx: number;
+};
+
+// the words from `from` up to `to` were typed for the player, who is on `to` now
+export type WordsSkipped = {
+	opcode: typeof ServerOp.WordsSkipped;
+	from: number;
+	to: number;
+};
+
+export const ResultStatus = {
+	Finished: 0,
+	DidNotFinish: 1,
+	Disconnected: 2,
+} as const;
+
+export type PlayerResult = {
+	playerId: number;
+	place: number;
+	status: (typeof ResultStatus)[keyof typeof ResultStatus];
+	wpm: number;
+	accuracy: number;
+	timeTakenMs: number;
+	powerupsUsed: PowerupId[];
+	effectsReceived: StatusEffectId[];
+};
+
+export type RaceResults = {
+	opcode: typeof ServerOp.RaceResults;
+	results: PlayerResult[];
+	// words completed by each player in results, for time attack races
+	wordsTyped: number[];
+};
+
+export type RaceCountdown = {
+	opcode: typeof ServerOp.RaceCountdown;
+	timeLeft: number;
+};
+
+export type ResumeState = {
+	opcode: typeof ServerOp.ResumeState;
+	idx: number;
+	powerups: PowerupId[];
+};
+
+export type SpectatorHello = {
+	opcode: typeof ServerOp.SpectatorHello;
+	lobbyId: number;
+	timeLeft: number;
+	raceStarted: boolean;
+	players: Player[];
+};
+
+export type Quote = {
+	opcode: typeof ServerOp.Quote;
+	author: string;
+	source: string;
+	length: (typeof QuoteLength)[keyof typeof QuoteLength];
+};
+
+export type TeamMember = {
+	playerId: number;
+	team: number;
+};
+
+export type TeamAssignment = {
+	opcode: typeof ServerOp.TeamAssignment;
+	teams: number;
+	members: TeamMember[];
+};
+
+// averaged over the team's members
+export type TeamProgress = {
+	opcode: typeof ServerOp.TeamProgress;
+	team: number;
+	progress: number;
+	wpm: number;
+};
+
+export type TeamResult = {
+	team: number;
+	place: number;
+	points: number;
+	progress: number;
+	wpm: number;
+	finished: number;
+	bestPlace: number;
+};
+
+// best team first
+export type TeamResults = {
+	opcode: typeof ServerOp.TeamResults;
+	results: TeamResult[];
+};
+
+export type Balance = {
+	opcode: typeof ServerOp.Balance;
+	balance: number;
+	streak: number;
+};
+
+export type PowerupCost = {
+	powerupId: PowerupId;
+	cost: number;
+};
+
+export type PowerupCosts = {
+	opcode: typeof ServerOp.PowerupCosts;
+	costs: PowerupCost[];
+};
+
 export type ServerMessage =
 	| HubHello
 	| LobbyHello
@@ -140,7 +400,21 @@ export type ServerMessage =
 	| PlayerFinished
 	| StatusChanged
 	| PurchaseResult
-	| UpdateWords;
+	| UpdateWords
+	| LobbyCode
+	| JoinFailed
+	| SubmissionRejected
+	| RaceResults
+	| RaceCountdown
+	| ResumeState
+	| SpectatorHello
+	| Quote
+	| TeamAssignment
+	| TeamProgress
+	| TeamResults
+	| Balance
+	| PowerupCosts
+	| WordsSkipped;
 
 const textDecoder = new TextDecoder("utf-8");
 const textEncoder = new TextEncoder();
@@ -149,17 +423,56 @@ function serializeClientMessage(payload: ClientMessage): ArrayBuffer {
 	let buffer, view;
 	const { opcode } = payload;
 	switch (opcode) {
-		case ClientOp.Register:
+		case ClientOp.Register: {
 			const nameEncoded = textEncoder.encode(payload.name);
 			if (nameEncoded.length > 255) throw new Error("Name too long");
-			buffer = new ArrayBuffer(1 + 1 + nameEncoded.length); // opcode + nameLen + name
+			const joinEncoded = textEncoder.encode(payload.code ?? "");
+			if (joinEncoded.length > 255) throw new Error("Code too long");
+			const codeLen = joinEncoded.length > 0 ? 1 + joinEncoded.length : 0;
+			buffer = new ArrayBuffer(1 + 1 + nameEncoded.length + codeLen); // opcode + nameLen + name + [codeLen + code]
 			view = new DataView(buffer);
 			view.setUint8(0, opcode);
 			view.setUint8(1, nameEncoded.length);
 			for (let i = 0; i < nameEncoded.length; i++) {
 				view.setUint8(2 + i, nameEncoded[i]);
 			}
+			if (codeLen > 0) {
+				const offset = 2 + nameEncoded.length;
+				view.setUint8(offset, joinEncoded.length);
+				for (let i = 0; i < joinEncoded.length; i++) {
+					view.setUint8(offset + 1 + i, joinEncoded[i]);
+				}
+			}
+			return buffer;
+		}
+
+		case ClientOp.CreateLobby: {
+			// every field is optional on the wire, but each one needs those before it
+			const fields = [payload.name, payload.source ?? "", payload.language ?? ""]
+				.map((f) => textEncoder.encode(f));
+			if (fields.some((f) => f.length > 255)) throw new Error("Field too long");
+			const withMode = payload.timeAttack !== undefined || payload.teams !== undefined;
+			if (!withMode && payload.language === undefined) {
+				fields.pop();
+				if (payload.source === undefined) fields.pop();
+			}
+			const size = fields.reduce((a, f) => a + 1 + f.length, 1) + (withMode ? 3 : 0);
+			buffer = new ArrayBuffer(size);
+			view = new DataView(buffer);
+			view.setUint8(0, opcode);
+			let offset = 1;
+			for (const f of fields) {
+				view.setUint8(offset++, f.length);
+				for (let i = 0; i < f.length; i++) {
+					view.setUint8(offset++, f[i]);
+				}
+			}
+			if (withMode) {
+				view.setUint16(offset, payload.timeAttack ?? 0);
+				view.setUint8(offset + 2, payload.teams ?? 0);
+			}
 			return buffer;
+		}
 
 		case ClientOp.Submit:
 			buffer = new ArrayBuffer(1 + 4); // opcode + answer
@@ -168,6 +481,65 @@ function serializeClientMessage(payload: ClientMessage): ArrayBuffer {
 			view.setUint32(1, payload.idx);
 			return buffer;
 
+		case ClientOp.SubmitWord:
+			const wordEncoded = textEncoder.encode(payload.word);
+			if (wordEncoded.length > 255) throw new Error("Word too long");
+			buffer = new ArrayBuffer(1 + 4 + 1 + wordEncoded.length); // opcode + idx + wordLen + word
+			view = new DataView(buffer);
+			view.setUint8(0, opcode);
+			view.setUint32(1, payload.idx);
+			view.setUint8(5, wordEncoded.length);
+			for (let i = 0; i < wordEncoded.length; i++) {
+				view.setUint8(6 + i, wordEncoded[i]);
+			}
+			return buffer;
+
+		case ClientOp.KeystrokeReport:
+			buffer = new ArrayBuffer(1 + 2 + 2);
+			view = new DataView(buffer);
+			view.setUint8(0, opcode);
+			view.setUint16(1, payload.incorrect);
+			view.setUint16(3, payload.backspaces);
+			return buffer;
+
+		case ClientOp.Resume:
+			const tokenEncoded = textEncoder.encode(payload.token);
+			if (tokenEncoded.length > 255) throw new Error("Token too long");
+			buffer = new ArrayBuffer(1 + 1 + tokenEncoded.length);
+			view = new DataView(buffer);
+			view.setUint8(0, opcode);
+			view.setUint8(1, tokenEncoded.length);
+			for (let i = 0; i < tokenEncoded.length; i++) {
+				view.setUint8(2 + i, tokenEncoded[i]);
+			}
+			return buffer;
+
+		case ClientOp.Spectate:
+			const codeEncoded = textEncoder.encode(payload.code ?? "");
+			if (codeEncoded.length > 255) throw new Error("Code too long");
+			buffer = new ArrayBuffer(1 + 4 + 1 + codeEncoded.length);
+			view = new DataView(buffer);
+			view.setUint8(0, opcode);
+			view.setUint32(1, payload.lobbyId);
+			view.setUint8(5, codeEncoded.length);
+			for (let i = 0; i < codeEncoded.length; i++) {
+				view.setUint8(6 + i, codeEncoded[i]);
+			}
+			return buffer;
+
+		case ClientOp.WatchReplay:
+			const idEncoded = textEncoder.encode(payload.replayId);
+			if (idEncoded.length > 255) throw new Error("Replay id too long");
+			buffer = new ArrayBuffer(1 + 1 + 1 + idEncoded.length);
+			view = new DataView(buffer);
+			view.setUint8(0, opcode);
+			view.setUint8(1, payload.speed);
+			view.setUint8(2, idEncoded.length);
+			for (let i = 0; i < idEncoded.length; i++) {
+				view.setUint8(3 + i, idEncoded[i]);
+			}
+			return buffer;
+
 		case ClientOp.SkipWait:
 			buffer = new ArrayBuffer(1);
 			view = new DataView(buffer);
@@ -236,6 +608,10 @@ function parseU8(view: DataView, offset: number): [number, number] {
 	return [view.getUint8(offset), offset + 1];
 }
 
+function parseU16(view: DataView, offset: number): [number, number] {
+	return [view.getUint16(offset), offset + 2];
+}
+
 function parseU32(view: DataView, offset: number): [number, number] {
 	return [view.getUint32(offset), offset + 4];
 }
@@ -257,6 +633,99 @@ function parseList<T>(
 	return [arr, offset];
 }
 
+function parsePowerupCost(
+	view: DataView,
+	offset: number
+): [PowerupCost, number] {
+	const powerupId = view.getUint8(offset++) as PowerupId;
+	const cost = view.getUint16(offset);
+	return [{ powerupId, cost }, offset + 2];
+}
+
+function parseEffectState(
+	view: DataView,
+	offset: number
+): [EffectState, number] {
+	const powerupId = view.getUint8(offset++) as StatusEffectId;
+	const sourcePlayerId = view.getUint8(offset++);
+	const intensity = view.getUint8(offset++);
+	const kind = view.getUint8(offset++) as EffectDurationKind;
+	const remaining = view.getUint32(offset);
+	return [
+		{ powerupId, sourcePlayerId, intensity, kind, remaining },
+		offset + 4,
+	];
+}
+
+function parseCooldownState(
+	view: DataView,
+	offset: number
+): [CooldownState, number] {
+	const powerupId = view.getUint8(offset++) as PowerupId;
+	const remaining = view.getUint32(offset);
+	return [{ powerupId, remaining }, offset + 4];
+}
+
+function parseTeamMember(
+	view: DataView,
+	offset: number
+): [TeamMember, number] {
+	const playerId = view.getUint8(offset++);
+	const team = view.getUint8(offset++);
+	return [{ playerId, team }, offset];
+}
+
+function parseTeamResult(
+	view: DataView,
+	offset: number
+): [TeamResult, number] {
+	const team = view.getUint8(offset++);
+	const place = view.getUint8(offset++);
+	const points = view.getFloat32(offset);
+	offset += 4;
+	const progress = view.getFloat32(offset);
+	offset += 4;
+	const wpm = view.getUint32(offset);
+	offset += 4;
+	const finished = view.getUint8(offset++);
+	const bestPlace = view.getUint8(offset++);
+	return [
+		{ team, place, points, progress, wpm, finished, bestPlace },
+		offset,
+	];
+}
+
+function parsePlayerResult(
+	view: DataView,
+	offset: number
+): [PlayerResult, number] {
+	const playerId = view.getUint8(offset++);
+	const place = view.getUint8(offset++);
+	const status = view.getUint8(offset++) as PlayerResult["status"];
+	const wpm = view.getUint32(offset);
+	offset += 4;
+	const accuracy = view.getFloat32(offset);
+	offset += 4;
+	const timeTakenMs = view.getUint32(offset);
+	offset += 4;
+	let powerupsUsed, effectsReceived;
+	[powerupsUsed, offset] = parseList(view, offset, parseEffectId, parseU16);
+	[effectsReceived, offset] = parseList(view, offset, parseEffectId, parseU16);
+	return [
+		{
+			playerId,
+			place,
+			status,
+			wpm,
+			accuracy,
+			timeTakenMs,
+			powerupsUsed,
+			effectsReceived,
+		},
+		offset,
+	];
+}
+
 function parseServerMessage(buffer: ArrayBuffer): ServerMessage {
 	const view = new DataView(buffer);
 	let offset = 0;
@@ -278,7 +747,22 @@ function parseServerMessage(buffer: ArrayBuffer): ServerMessage {
 			[words, offset] = parseList(view, offset, parseWord, parseU32);
 			let powerups;
 			[powerups, offset] = parseList(view, offset, parseEffectId);
-			return { players, opcode, words, timeLeft, playerId, powerups };
+			let resumeToken = "";
+			let lobbyId = 0;
+			if (offset < view.byteLength) {
+				[resumeToken, offset] = parseWord(view, offset);
+				[lobbyId, offset] = parseU32(view, offset);
+			}
+			return {
+				players,
+				opcode,
+				words,
+				timeLeft,
+				playerId,
+				powerups,
+				resumeToken,
+				lobbyId,
+			};
 		}
 
 		case ServerOp.NewPlayer: {
@@ -287,35 +771,69 @@ function parseServerMessage(buffer: ArrayBuffer): ServerMessage {
 			return { ...player, opcode };
 		}
 
-		case ServerOp.PlayerFinished: {
+		case ServerOp.ProgressUpdateV2: {
 			const playerId = view.getUint8(offset++);
-			const place = view.getUint8(offset++);
-			return { opcode, id: playerId, place };
+			const progress = view.getFloat32(offset, false);
+			offset += 4;
+			const wpm = view.getUint32(offset, false);
+			offset += 4;
+			const rawWpm = view.getUint32(offset, false);
+			offset += 4;
+			const accuracy = view.getFloat32(offset, false);
+			return {
+				opcode: ServerOp.ProgressUpdate,
+				playerId,
+				progress,
+				wpm,
+				rawWpm,
+				accuracy,
+			};
 		}
 
-		case ServerOp.ProgressUpdate: {
+		case ServerOp.PlayerFinishedV2: {
 			const playerId = view.getUint8(offset++);
-			const progress = view.getFloat32(offset, false);
-			offset += 4;
+			const place = view.getUint8(offset++);
 			const wpm = view.getUint32(offset, false);
-			return { opcode, playerId, progress, wpm };
+			offset += 4;
+			const accuracy = view.getFloat32(offset, false);
+			return {
+				opcode: ServerOp.PlayerFinished,
+				id: playerId,
+				place,
+				wpm,
+				accuracy,
+			};
 		}
 
 		case ServerOp.StartGame: {
 			return { opcode };
 		}
 
-		case ServerOp.StatusChanged: {
+		case ServerOp.StatusChangedV2: {
 			const playerId = view.getUint8(offset++);
-			let statusEffects;
-			[statusEffects, offset] = parseList(view, offset, parseEffectId);
-			return { opcode, statusEffects, playerId };
+			let effects, cooldowns;
+			[effects, offset] = parseList(view, offset, parseEffectState);
+			const immunity = view.getUint32(offset);
+			offset += 4;
+			[cooldowns, offset] = parseList(view, offset, parseCooldownState);
+			return {
+				opcode: ServerOp.StatusChanged,
+				playerId,
+				statusEffects: effects.map((e) => e.powerupId),
+				effects,
+				immunity,
+				cooldowns,
+			};
 		}
 
 		case ServerOp.PurchaseResult: {
 			const powerupId = view.getUint8(offset++) as PowerupId;
 			const success = view.getUint8(offset++) === 1;
-			return { opcode, powerupId, success };
+			let balance;
+			if (offset < view.byteLength) {
+				balance = view.getUint32(offset);
+			}
+			return { opcode, powerupId, success, balance };
 		}
 
 		case ServerOp.UpdateWords: {
@@ -326,6 +844,103 @@ function parseServerMessage(buffer: ArrayBuffer): ServerMessage {
 			return { opcode, startIndex, words };
 		}
 
+		case ServerOp.RaceResults: {
+			let results;
+			[results, offset] = parseList(view, offset, parsePlayerResult);
+			let wordsTyped: number[] = [];
+			if (offset < view.byteLength) {
+				[wordsTyped, offset] = parseList(view, offset, parseU32);
+			}
+			return { opcode, results, wordsTyped };
+		}
+
+		case ServerOp.RaceCountdown: {
+			const timeLeft = view.getUint16(offset);
+			return { opcode, timeLeft };
+		}
+
+		case ServerOp.ResumeState: {
+			const idx = view.getUint32(offset);
+			offset += 4;
+			let powerups;
+			[powerups, offset] = parseList(view, offset, parseEffectId);
+			return { opcode, idx, powerups };
+		}
+
+		case ServerOp.SpectatorHello: {
+			let lobbyId;
+			[lobbyId, offset] = parseU32(view, offset);
+			const timeLeft = view.getUint16(offset);
+			offset += 2;
+			const raceStarted = view.getUint8(offset++) === 1;
+			let players;
+			[players, offset] = parseList(view, offset, parsePlayer);
+			return { opcode, lobbyId, timeLeft, raceStarted, players };
+		}
+
+		case ServerOp.Quote: {
+			let author, source;
+			[author, offset] = parseWord(view, offset);
+			[source, offset] = parseWord(view, offset);
+			const length = view.getUint8(offset++) as Quote["length"];
+			return { opcode, author, source, length };
+		}
+
+		case ServerOp.TeamAssignment: {
+			const teams = view.getUint8(offset++);
+			let members;
+			[members, offset] = parseList(view, offset, parseTeamMember);
+			return { opcode, teams, members };
+		}
+
+		case ServerOp.TeamProgress: {
+			const team = view.getUint8(offset++);
+			const progress = view.getFloat32(offset);
+			offset += 4;
+			const wpm = view.getUint32(offset);
+			return { opcode, team, progress, wpm };
+		}
+
+		case ServerOp.TeamResults: {
+			let results;
+			[results, offset] = parseList(view, offset, parseTeamResult);
+			return { opcode, results };
+		}
+
+		case ServerOp.Balance: {
+			const balance = view.getUint32(offset);
+			const streak = view.getUint16(offset + 4);
+			return { opcode, balance, streak };
+		}
+
+		case ServerOp.PowerupCosts: {
+			let costs;
+			[costs, offset] = parseList(view, offset, parsePowerupCost);
+			return { opcode, costs };
+		}
+
+		case ServerOp.LobbyCode: {
+			let code;
+			[code, offset] = parseWord(view, offset);
+			return { opcode, code };
+		}
+
+		case ServerOp.JoinFailed: {
+			const reason = view.getUint8(offset++) as JoinFailedReason;
+			return { opcode, reason };
+		}
+
+		case ServerOp.SubmissionRejected: {
+			const idx = view.getUint32(offset);
+			return { opcode, idx };
+		}
+
+		case ServerOp.WordsSkipped: {
+			const from = view.getUint32(offset);
+			const to = view.getUint32(offset + 4);
+			return { opcode, from, to };
+		}
+
 		default:
 			throw new Error("Unknown opcode: " + opcode);
 	}
@@ -347,9 +962,34 @@ export type Socket = {
 		onStatusChanged: (arg0: (arg0: StatusChanged) => void) => void;
 		onPurchaseResult: (arg0: (arg0: PurchaseResult) => void) => void;
 		onUpdateWords: (arg0: (arg0: UpdateWords) => void) => void;
+		onLobbyCode: (arg0: (arg0: LobbyCode) => void) => void;
+		onJoinFailed: (arg0: (arg0: JoinFailed) => void) => void;
+		onSubmissionRejected: (
+			arg0: (arg0: SubmissionRejected) => void
+		) => void;
+		onRaceResults: (arg0: (arg0: RaceResults) => void) => void;
+		onRaceCountdown: (arg0: (arg0: RaceCountdown) => void) => void;
+		onResumeState: (arg0: (arg0: ResumeState) => void) => void;
+		onSpectatorHello: (arg0: (arg0: SpectatorHello) => void) => void;
+		onQuote: (arg0: (arg0: Quote) => void) => void;
+		onTeamAssignment: (arg0: (arg0: TeamAssignment) => void) => void;
+		onTeamProgress: (arg0: (arg0: TeamProgress) => void) => void;
+		onTeamResults: (arg0: (arg0: TeamResults) => void) => void;
+		onBalance: (arg0: (arg0: Balance) => void) => void;
+		onPowerupCosts: (arg0: (arg0: PowerupCosts) => void) => void;
+		onWordsSkipped: (arg0: (arg0: WordsSkipped) => void) => void;
 	};
-	sendRegister: (name: string) => void;
+	sendRegister: (name: string, code?: string) => void;
+	sendCreateLobby: (
+		name: string,
+		options?: Omit<CreateLobbyMessage, "opcode" | "name">
+	) => void;
 	sendSubmit: (idx: number) => void;
+	sendSubmitWord: (idx: number, word: string) => void;
+	sendKeystrokes: (incorrect: number, backspaces: number) => void;
+	sendResume: (token: string) => void;
+	sendSpectate: (lobbyId: number, code?: string) => void;
+	sendWatchReplay: (replayId: string, speed: number) => void;
 	sendSkip: () => void;
 	sendSelect: (arg0: PowerupId[]) => void;
 	sendPurchase: (arg0: Purchase) => void;
@@ -405,10 +1045,50 @@ async function connect_raw(url: string): Promise<Socket> {
 				callIfOpCode(handler, ServerOp.PurchaseResult),
 			onUpdateWords: (handler: (arg0: UpdateWords) => void) =>
 				callIfOpCode(handler, ServerOp.UpdateWords),
+			onLobbyCode: (handler: (arg0: LobbyCode) => void) =>
+				callIfOpCode(handler, ServerOp.LobbyCode),
+			onJoinFailed: (handler: (arg0: JoinFailed) => void) =>
+				callIfOpCode(handler, ServerOp.JoinFailed),
+			onSubmissionRejected: (handler: (arg0: SubmissionRejected) => void) =>
+				callIfOpCode(handler, ServerOp.SubmissionRejected),
+			onRaceResults: (handler: (arg0: RaceResults) => void) =>
+				callIfOpCode(handler, ServerOp.RaceResults),
+			onRaceCountdown: (handler: (arg0: RaceCountdown) => void) =>
+				callIfOpCode(handler, ServerOp.RaceCountdown),
+			onResumeState: (handler: (arg0: ResumeState) => void) =>
+				callIfOpCode(handler, ServerOp.ResumeState),
+			onSpectatorHello: (handler: (arg0: SpectatorHello) => void) =>
+				callIfOpCode(handler, ServerOp.SpectatorHello),
+			onQuote: (handler: (arg0: Quote) => void) =>
+				callIfOpCode(handler, ServerOp.Quote),
+			onTeamAssignment: (handler: (arg0: TeamAssignment) => void) =>
+				callIfOpCode(handler, ServerOp.TeamAssignment),
+			onTeamProgress: (handler: (arg0: TeamProgress) => void) =>
+				callIfOpCode(handler, ServerOp.TeamProgress),
+			onTeamResults: (handler: (arg0: TeamResults) => void) =>
+				callIfOpCode(handler, ServerOp.TeamResults),
+			onBalance: (handler: (arg0: Balance) => void) =>
+				callIfOpCode(handler, ServerOp.Balance),
+			onPowerupCosts: (handler: (arg0: PowerupCosts) => void) =>
+				callIfOpCode(handler, ServerOp.PowerupCosts),
+			onWordsSkipped: (handler: (arg0: WordsSkipped) => void) =>
+				callIfOpCode(handler, ServerOp.WordsSkipped),
+		},
+		sendRegister: (name: string, code?: string) => {
+			socket.send(
+				serializeClientMessage({ opcode: ClientOp.Register, name, code })
+			);
 		},
-		sendRegister: (name: string) => {
+		sendCreateLobby: (
+			name: string,
+			options?: Omit<CreateLobbyMessage, "opcode" | "name">
+		) => {
 			socket.send(
-				serializeClientMessage({ opcode: ClientOp.Register, name })
+				serializeClientMessage({
+					opcode: ClientOp.CreateLobby,
+					name,
+					...options,
+				})
 			);
 		},
 		sendSubmit: (idx: number) => {
@@ -416,6 +1096,39 @@ async function connect_raw(url: string): Promise<Socket> {
 				serializeClientMessage({ opcode: ClientOp.Submit, idx })
 			);
 		},
+		sendSubmitWord: (idx: number, word: string) => {
+			socket.send(
+				serializeClientMessage({ opcode: ClientOp.SubmitWord, idx, word })
+			);
+		},
+		sendKeystrokes: (incorrect: number, backspaces: number) => {
+			socket.send(
+				serializeClientMessage({
+					opcode: ClientOp.KeystrokeReport,
+					incorrect,
+					backspaces,
+				})
+			);
+		},
+		sendResume: (token: string) => {
+			socket.send(
+				serializeClientMessage({ opcode: ClientOp.Resume, token })
+			);
+		},
+		sendSpectate: (lobbyId: number, code?: string) => {
+			socket.send(
+				serializeClientMessage({ opcode: ClientOp.Spectate, lobbyId, code })
+			);
+		},
+		sendWatchReplay: (replayId: string, speed: number) => {
+			socket.send(
+				serializeClientMessage({
+					opcode: ClientOp.WatchReplay,
+					replayId,
+					speed,
+				})
+			);
+		},
 		sendSkip: () => {
 			socket.send(serializeClientMessage({ opcode: ClientOp.SkipWait }));
 		},
@@ -6,6 +6,12 @@ import snow from "@/assets/snow.svg";
 import tire from "@/assets/tire.svg";
 import scramble from "@/assets/scramble.svg";
 import mirror from "@/assets/mirror.svg";
+import leftright from "@/assets/left-right.svg";
+import pin from "@/assets/close.svg";
+import updown from "@/assets/up-down.svg";
+import trend from "@/assets/last.svg";
+import medal from "@/assets/first.svg";
+import pointer from "@/assets/active.svg";
 
 export const POWERUP_INFO = {
 	[Powerup.SpikeStrip]: {
@@ -50,6 +56,60 @@ export const POWERUP_INFO = {
 		icon: mirror,
 		description: "Reflect other skills back for 10 seconds",
 	},
+	[Powerup.ReverseGear]: {
+		id: Powerup.ReverseGear,
+		name: "Reverse Gear",
+		icon: leftright,
+		description: "Spell opponent's next 8 words backwards",
+	},
+	[Powerup.Potholes]: {
+		id: Powerup.Potholes,
+		name: "Potholes",
+		icon: pin,
+		description: "Drop the vowels from opponent's next 10 words",
+	},
+	[Powerup.Mirage]: {
+		id: Powerup.Mirage,
+		name: "Mirage",
+		icon: fog,
+		description: "Swap letters for lookalikes in opponent's next 10 words",
+	},
+	[Powerup.BumpyRoad]: {
+		id: Powerup.BumpyRoad,
+		name: "Bumpy Road",
+		icon: updown,
+		description: "Randomly capitalize opponent's next 10 words",
+	},
+	[Powerup.Tailgate]: {
+		id: Powerup.Tailgate,
+		name: "Tailgate",
+		icon: tire,
+		description: "Run opponent's next 10 words together in pairs",
+	},
+	[Powerup.Shield]: {
+		id: Powerup.Shield,
+		name: "Shield",
+		icon: medal,
+		description: "Block the next skill used on you within 15 seconds",
+	},
+	[Powerup.Nitro]: {
+		id: Powerup.Nitro,
+		name: "Nitro",
+		icon: trend,
+		description: "Words count for 50% more progress for 8 seconds",
+	},
+	[Powerup.Cleanse]: {
+		id: Powerup.Cleanse,
+		name: "Cleanse",
+		icon: pointer,
+		description: "Remove opponents' skills and restore your words",
+	},
+	[Powerup.Autocorrect]: {
+		id: Powerup.Autocorrect,
+		name: "Autocorrect",
+		icon: shift,
+		description: "Skip your next 3 words",
+	},
 } satisfies Record<
 	number,
 	{